@@ -1,15 +1,26 @@
 package sls
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 )
 
-// Error defines sls error
+// Error defines sls error.
+// The SDK methods return their failures as *Error, except the context
+// errors of cancelled or timed out requests, which are returned as is.
+// The errors raised by the SDK itself, e.g. network errors, have code
+// ClientError and wrap their cause.
 type Error struct {
 	Code      string `json:"errorCode"`
 	Message   string `json:"errorMessage"`
 	RequestID string `json:"requestID"`
+	HTTPCode  int    `json:"httpCode,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty"`  // Endpoint the request was sent to
+	Operation string `json:"operation,omitempty"` // Name of the SDK method, e.g. "PutLogs"
+
+	Err error `json:"-"` // Cause of a ClientError, if any
 }
 
 // NewClientError new client error
@@ -20,6 +31,33 @@ func NewClientError(message string) *Error {
 	return err
 }
 
+// clientError wraps err as a ClientError. Context errors and *Error are
+// returned as is, so callers can tell a cancelled or timed out request
+// apart with err == context.Canceled or err == context.DeadlineExceeded.
+func clientError(err error) error {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	e := NewClientError(err.Error())
+	e.Err = err
+	return e
+}
+
+// Unwrap returns the cause of the error, for errors.Is and errors.As.
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Is tells whether target is an *Error with the same code, so that
+// errors.Is(err, &Error{Code: "LogStoreNotExist"}) checks the code of err.
+func (e Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
 func (e Error) String() string {
 	b, err := json.MarshalIndent(e, "", "    ")
 	if err != nil {
@@ -34,24 +72,86 @@ func (e Error) Error() string {
 
 // Client ...
 type Client struct {
-	Endpoint        string // IP or hostname of SLS endpoint
+	Endpoint        string // IP or hostname of SLS endpoint, or a URL, see LogProject.Endpoint
+	ProjectInHeader bool   // See LogProject.ProjectInHeader
+
+	SignatureVersion SignatureVersion // See LogProject.SignatureVersion
+	Region           string           // See LogProject.Region
+
 	AccessKeyID     string
 	AccessKeySecret string
 	SecurityToken   string
+
+	// CredentialsProvider provides the credentials of this client and the
+	// projects it returns. A nil CredentialsProvider means the static
+	// AccessKeyID, AccessKeySecret and SecurityToken.
+	CredentialsProvider CredentialsProvider
+
+	// HTTPClient sends the requests of this client and the projects it
+	// returns. A nil HTTPClient means defaultHTTPClient.
+	HTTPClient *http.Client
+
+	// RetryPolicy decides which failed requests of this client and the
+	// projects it returns are retried. A nil RetryPolicy means DefaultRetryPolicy.
+	RetryPolicy RetryPolicy
+
+	// Interceptors intercept the requests of this client and the projects
+	// it returns, see Interceptor.
+	Interceptors []Interceptor
+
+	// Metrics records the requests of this client and the projects it
+	// returns, nil means no metrics.
+	Metrics Metrics
+
+	// Tracer traces the requests of this client and the projects it
+	// returns, nil means no tracing.
+	Tracer Tracer
+
+	// RateLimiter throttles the requests of this client and the projects
+	// it returns, nil means no client side limit.
+	RateLimiter *RateLimiter
+
+	// EndpointPool, if any, is used instead of Endpoint to fail over to
+	// other endpoints, see EndpointPool.
+	EndpointPool *EndpointPool
+
+	// Logger logs the requests of this client and the projects it returns,
+	// nil means no logs.
+	Logger Logger
 }
 
 func convert(c *Client, projName string) *LogProject {
 	return &LogProject{
 		Name:            projName,
 		Endpoint:        c.Endpoint,
+		ProjectInHeader: c.ProjectInHeader,
+
+		SignatureVersion: c.SignatureVersion,
+		Region:           c.Region,
+
 		AccessKeyID:     c.AccessKeyID,
 		AccessKeySecret: c.AccessKeySecret,
 		SecurityToken:   c.SecurityToken,
+
+		CredentialsProvider: c.CredentialsProvider,
+		HTTPClient:          c.HTTPClient,
+		RetryPolicy:         c.RetryPolicy,
+		Interceptors:        c.Interceptors,
+		Metrics:             c.Metrics,
+		Tracer:              c.Tracer,
+		RateLimiter:         c.RateLimiter,
+		EndpointPool:        c.EndpointPool,
+		Logger:              c.Logger,
 	}
 }
 
 // CreateProject create a new loghub project.
 func (c *Client) CreateProject(name, description string) (*LogProject, error) {
+	return c.CreateProjectWithContext(context.Background(), name, description)
+}
+
+// CreateProjectWithContext is like CreateProject but uses ctx to cancel the request.
+func (c *Client) CreateProjectWithContext(ctx context.Context, name, description string) (*LogProject, error) {
 	type Body struct {
 		ProjectName string `json:"projectName"`
 		Description string `json:"description"`
@@ -60,19 +160,19 @@ func (c *Client) CreateProject(name, description string) (*LogProject, error) {
 		ProjectName: name,
 		Description: description,
 	})
+	proj := convert(c, name)
 	if err != nil {
-		return nil, err
+		return nil, proj.opError(err, "CreateProject", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%d", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+		"Accept-Encoding":   DefaultCompressType,
 	}
 
 	uri := "/"
-	proj := convert(c, name)
-	_, err = request(proj, "POST", uri, h, body)
+	_, err = request(ctx, proj, "CreateProject", "POST", uri, h, body)
 	if err != nil {
 		return nil, err
 	}
@@ -82,13 +182,18 @@ func (c *Client) CreateProject(name, description string) (*LogProject, error) {
 
 // GetProject ...
 func (c *Client) GetProject(name string) (*LogProject, error) {
+	return c.GetProjectWithContext(context.Background(), name)
+}
+
+// GetProjectWithContext is like GetProject but uses ctx to cancel the request.
+func (c *Client) GetProjectWithContext(ctx context.Context, name string) (*LogProject, error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 
 	uri := "/"
 	proj := convert(c, name)
-	_, err := request(proj, "GET", uri, h, nil)
+	_, err := request(ctx, proj, "GetProject", "GET", uri, h, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -98,12 +203,17 @@ func (c *Client) GetProject(name string) (*LogProject, error) {
 
 // CheckProjectExist check project exist or not
 func (c *Client) CheckProjectExist(name string) (bool, error) {
+	return c.CheckProjectExistWithContext(context.Background(), name)
+}
+
+// CheckProjectExistWithContext is like CheckProjectExist but uses ctx to cancel the request.
+func (c *Client) CheckProjectExistWithContext(ctx context.Context, name string) (bool, error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 	uri := "/"
 	proj := convert(c, name)
-	_, err := request(proj, "GET", uri, h, nil)
+	_, err := request(ctx, proj, "CheckProjectExist", "GET", uri, h, nil)
 	if err != nil {
 		if _, ok := err.(*Error); ok {
 			slsErr := err.(*Error)
@@ -119,13 +229,18 @@ func (c *Client) CheckProjectExist(name string) (bool, error) {
 
 // DeleteProject ...
 func (c *Client) DeleteProject(name string) error {
+	return c.DeleteProjectWithContext(context.Background(), name)
+}
+
+// DeleteProjectWithContext is like DeleteProject but uses ctx to cancel the request.
+func (c *Client) DeleteProjectWithContext(ctx context.Context, name string) error {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 
 	proj := convert(c, name)
 	uri := "/"
-	_, err := request(proj, "DELETE", uri, h, nil)
+	_, err := request(ctx, proj, "DeleteProject", "DELETE", uri, h, nil)
 	if err != nil {
 		return err
 	}
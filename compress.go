@@ -0,0 +1,202 @@
+package sls
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compress types supported by SLS, the names of the built-in Compressors.
+const (
+	CompressNone    = "none"
+	CompressLZ4     = "lz4"
+	CompressDeflate = "deflate"
+	CompressZstd    = "zstd"
+
+	// DefaultCompressType is used by the logstores without their own compress type.
+	DefaultCompressType = CompressLZ4
+)
+
+// Compressor compresses request bodies and decompresses response bodies
+// with a compress type of SLS, i.e. a value of the 'x-log-compresstype'
+// and 'Accept-Encoding' headers.
+type Compressor interface {
+	Name() string
+	Compress(raw []byte) ([]byte, error)
+	Decompress(data []byte, rawSize int) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = make(map[string]Compressor)
+)
+
+func init() {
+	RegisterCompressor(noneCompressor{})
+	RegisterCompressor(lz4Compressor{})
+	RegisterCompressor(deflateCompressor{})
+	RegisterCompressor(&zstdCompressor{})
+}
+
+// RegisterCompressor registers c under c.Name(), replacing the compressor
+// previously registered with the same name, if any.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Name()] = c
+}
+
+// GetCompressor returns the compressor registered with name, or nil.
+func GetCompressor(name string) Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	return compressors[name]
+}
+
+// readRaw reads the data decompressed by r, which should be rawSize bytes.
+// It stops reading after rawSize+1 bytes, so a body announcing a raw size
+// smaller than its actual one can't exhaust the memory.
+func readRaw(r io.Reader, rawSize int) ([]byte, error) {
+	raw := bytes.NewBuffer(make([]byte, 0, rawSize))
+	if _, err := raw.ReadFrom(io.LimitReader(r, int64(rawSize)+1)); err != nil {
+		return nil, err
+	}
+	return checkRawSize(raw.Bytes(), rawSize)
+}
+
+// checkRawSize checks the size of decompressed data.
+func checkRawSize(raw []byte, rawSize int) ([]byte, error) {
+	if len(raw) != rawSize {
+		return nil, fmt.Errorf("bad raw size:%v, expected:%v", len(raw), rawSize)
+	}
+	return raw, nil
+}
+
+// noneCompressor sends the bodies as is.
+type noneCompressor struct{}
+
+func (noneCompressor) Name() string { return CompressNone }
+
+func (noneCompressor) Compress(raw []byte) ([]byte, error) {
+	return raw, nil
+}
+
+func (noneCompressor) Decompress(data []byte, rawSize int) ([]byte, error) {
+	return checkRawSize(data, rawSize)
+}
+
+// deflateCompressor uses the zlib format, like java.util.zip.Deflater.
+type deflateCompressor struct{}
+
+func (deflateCompressor) Name() string { return CompressDeflate }
+
+func (deflateCompressor) Compress(raw []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (deflateCompressor) Decompress(data []byte, rawSize int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readRaw(r, rawSize)
+}
+
+// zstdCompressor uses the zstd format. Its encoder is created on first use
+// and shared, it's safe for concurrent use. Its decoders stream the data
+// to stop at the raw size, they're pooled.
+type zstdCompressor struct {
+	once     sync.Once
+	encoder  *zstd.Encoder
+	err      error
+	decoders sync.Pool
+}
+
+func (c *zstdCompressor) Name() string { return CompressZstd }
+
+func (c *zstdCompressor) init() error {
+	c.once.Do(func() {
+		c.encoder, c.err = zstd.NewWriter(nil)
+	})
+	return c.err
+}
+
+func (c *zstdCompressor) Compress(raw []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.encoder.EncodeAll(raw, nil), nil
+}
+
+func (c *zstdCompressor) Decompress(data []byte, rawSize int) ([]byte, error) {
+	d, _ := c.decoders.Get().(*zstd.Decoder)
+	if d == nil {
+		// A single goroutine, so that the pooled decoders don't leak any.
+		var err error
+		d, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(MaxRawSize))
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer c.decoders.Put(d)
+	if err := d.Reset(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	defer d.Reset(nil)
+	return readRaw(d, rawSize)
+}
+
+// MaxRawSize is the max raw size of a compressed response body, the
+// responses announcing a larger 'x-log-bodyrawsize' are rejected before
+// allocating their raw body.
+const MaxRawSize = 20 * MaxLogGroupSize
+
+// decompressResponse replaces the body of a response compressed with the
+// compress type in its 'x-log-compresstype' header by the raw body.
+func decompressResponse(resp *http.Response) error {
+	compressType := resp.Header.Get("x-log-compresstype")
+	if compressType == "" {
+		return nil
+	}
+	c := GetCompressor(compressType)
+	if c == nil {
+		return fmt.Errorf("unexpected compress type:%v", compressType)
+	}
+	rawSize, err := strconv.Atoi(resp.Header.Get("x-log-bodyrawsize"))
+	if err != nil {
+		return fmt.Errorf("bad 'x-log-bodyrawsize' header:%v", err)
+	}
+	if rawSize < 0 || rawSize > MaxRawSize {
+		return fmt.Errorf("bad 'x-log-bodyrawsize' header:%v, expected 0 to %v", rawSize, MaxRawSize)
+	}
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	raw, err := c.Decompress(buf, rawSize)
+	if err != nil {
+		return err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	resp.ContentLength = int64(len(raw))
+	resp.Header.Del("x-log-compresstype")
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build !cgolz4
+// +build !cgolz4
+
+package sls
+
+// lz4Compressor uses the lz4 block format, with the pure-Go implementation.
+// Build with tag cgolz4 to use the cgo one of github.com/cloudflare/golz4.
+type lz4Compressor struct{}
+
+func (lz4Compressor) Name() string { return CompressLZ4 }
+
+func (lz4Compressor) Compress(raw []byte) ([]byte, error) {
+	out := make([]byte, lz4CompressBound(len(raw)))
+	n, err := lz4Compress(raw, out)
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
+
+func (lz4Compressor) Decompress(data []byte, rawSize int) ([]byte, error) {
+	out := make([]byte, rawSize)
+	if err := lz4Uncompress(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,31 @@
+//go:build cgolz4
+// +build cgolz4
+
+package sls
+
+import (
+	lz4 "github.com/cloudflare/golz4"
+)
+
+// lz4Compressor uses the lz4 block format, with the cgo implementation of
+// github.com/cloudflare/golz4.
+type lz4Compressor struct{}
+
+func (lz4Compressor) Name() string { return CompressLZ4 }
+
+func (lz4Compressor) Compress(raw []byte) ([]byte, error) {
+	out := make([]byte, lz4.CompressBound(raw))
+	n, err := lz4.Compress(raw, out)
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
+
+func (lz4Compressor) Decompress(data []byte, rawSize int) ([]byte, error) {
+	out := make([]byte, rawSize)
+	if err := lz4.Uncompress(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,120 @@
+package sls
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestCompressors(t *testing.T) {
+	raw := bytes.Repeat([]byte("Every compressor must return the raw data. "), 100)
+	for _, name := range []string{CompressNone, CompressLZ4, CompressDeflate, CompressZstd} {
+		c := GetCompressor(name)
+		if c == nil {
+			t.Fatalf("%v isn't registered", name)
+		}
+		data, err := c.Compress(raw)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if name != CompressNone && len(data) >= len(raw) {
+			t.Errorf("%v: compressed size %v >= raw size %v", name, len(data), len(raw))
+		}
+		out, err := c.Decompress(data, len(raw))
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if !bytes.Equal(out, raw) {
+			t.Errorf("%v: decompressed data doesn't match", name)
+		}
+		if name != CompressLZ4 {
+			if _, err = c.Decompress(data, len(raw)+1); err == nil {
+				t.Errorf("%v: expected an error with a bad raw size", name)
+			}
+		}
+	}
+}
+
+func TestWithCompressType(t *testing.T) {
+	s := &LogStore{}
+	if s.compressor().Name() != DefaultCompressType {
+		t.Errorf("expected the default compressor, got %v", s.compressor().Name())
+	}
+	if _, err := s.WithCompressType("unknown"); err == nil {
+		t.Errorf("expected an error with an unknown compress type")
+	}
+	s.WithCompressType(CompressNone)
+	if s.acceptEncoding() != "" {
+		t.Errorf("expected no Accept-Encoding, got %v", s.acceptEncoding())
+	}
+
+	// The requests of the logstore have no Accept-Encoding header.
+	rt := &scriptTransport{}
+	s.Name, s.project = "test-compress", newRetryTestProject(rt)
+	s.CreateIndex(Index{})
+	if len(rt.reqs) != 1 {
+		t.Fatalf("expected 1 request, got %v", len(rt.reqs))
+	}
+	if _, ok := rt.reqs[0].Header["Accept-Encoding"]; ok {
+		t.Errorf("unexpected Accept-Encoding:%q", rt.reqs[0].Header.Get("Accept-Encoding"))
+	}
+}
+
+func TestDecompressResponse(t *testing.T) {
+	raw := []byte(`{"logstores": ["test-logstore"]}`)
+	data, _ := GetCompressor(CompressDeflate).Compress(raw)
+	resp := &http.Response{
+		Header: http.Header{},
+		Body:   ioutil.NopCloser(bytes.NewReader(data)),
+	}
+	resp.Header.Set("x-log-compresstype", CompressDeflate)
+	resp.Header.Set("x-log-bodyrawsize", strconv.Itoa(len(raw)))
+	if err := decompressResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := ioutil.ReadAll(resp.Body); !bytes.Equal(out, raw) {
+		t.Errorf("bad body:%s", out)
+	}
+
+	resp.Header.Set("x-log-compresstype", "unknown")
+	if err := decompressResponse(resp); err == nil {
+		t.Errorf("expected an error with an unknown compress type")
+	}
+
+	for _, name := range []string{CompressLZ4, CompressZstd, CompressDeflate, CompressNone} {
+		for _, rawSize := range []int{-1, MaxRawSize + 1} {
+			resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+			resp.Header.Set("x-log-compresstype", name)
+			resp.Header.Set("x-log-bodyrawsize", strconv.Itoa(rawSize))
+			if err := decompressResponse(resp); err == nil {
+				t.Errorf("%v: expected an error with raw size %v", name, rawSize)
+			}
+		}
+	}
+}
+
+func TestDecompressLyingRawSize(t *testing.T) {
+	// 32MB of zeros compress to a small body, announcing 1KB.
+	raw := make([]byte, 32<<20)
+	for _, name := range []string{CompressLZ4, CompressZstd, CompressDeflate} {
+		c := GetCompressor(name)
+		data, err := c.Compress(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		_, err = c.Decompress(data, 1024)
+		runtime.ReadMemStats(&after)
+		if err == nil {
+			t.Errorf("%v: expected an error with a lying raw size", name)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 16<<20 {
+			t.Errorf("%v: %v bytes allocated to decompress, expected at most 16MB", name, n)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package sls
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default values of ConsumerConfig.
+const (
+	DefaultConsumerGroupTimeout       = 60 * time.Second
+	DefaultConsumerHeartbeatInterval  = 20 * time.Second
+	DefaultConsumerCheckpointInterval = 10 * time.Second
+	DefaultConsumerFetchInterval      = time.Second
+	DefaultConsumerMaxFetchCount      = 1000
+)
+
+// ConsumerProcessor processes the logs pulled by a ConsumerWorker.
+// Process is called from one goroutine per shard. If it returns an error,
+// the same logs are processed again after ConsumerConfig.FetchInterval.
+type ConsumerProcessor interface {
+	Process(shardID int, gl *LogGroupList) error
+}
+
+// ConsumerProcessorFunc adapts a function to a ConsumerProcessor.
+type ConsumerProcessorFunc func(shardID int, gl *LogGroupList) error
+
+// Process calls f(shardID, gl).
+func (f ConsumerProcessorFunc) Process(shardID int, gl *LogGroupList) error {
+	return f(shardID, gl)
+}
+
+// ConsumerConfig defines how a ConsumerWorker consumes a logstore.
+// The zero value of a field means its default value.
+type ConsumerConfig struct {
+	ConsumerGroup string // Name of the consumer group, created if it doesn't exist
+	Consumer      string // Name of this consumer, unique in the consumer group
+
+	GroupTimeout       time.Duration // Time without heartbeat before a consumer loses its shards
+	InOrder            bool          // Consume the shards split or merged in order
+	HeartbeatInterval  time.Duration // Interval between two heartbeats, should be less than GroupTimeout
+	CheckpointInterval time.Duration // Interval between two checkpoints of a shard
+	FetchInterval      time.Duration // Wait time after pulling no logs or failing to
+	MaxFetchCount      int           // Max number of log groups pulled at once
+
+	// CursorPosition is where the shards without checkpoint are consumed
+	// from: OffsetOldest (default), OffsetNewest or a unix timestamp.
+	CursorPosition string
+}
+
+// ConsumerWorker consumes a logstore as a member of a consumer group.
+//
+// The worker sends heartbeats to the consumer group to get the shards
+// assigned to it, consumes each of them in its own goroutine, and saves
+// their checkpoints periodically and when they're assigned to another
+// consumer. Several workers of the same consumer group, in the same process
+// or not, share the shards of the logstore. A read-only shard consumed to
+// its end is released.
+type ConsumerWorker struct {
+	store     *LogStore
+	config    ConsumerConfig
+	processor ConsumerProcessor
+
+	mu       sync.Mutex
+	shards   map[int]*shardConsumer
+	finished map[int]bool // Read-only shards consumed to their end
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// commitCtx bounds the final checkpoints of the shards, it's canceled
+	// by abortCommits when CloseWithContext gives up.
+	commitCtx    context.Context
+	abortCommits context.CancelFunc
+}
+
+// shardConsumer is the goroutine consuming a shard.
+type shardConsumer struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewConsumerWorker creates a worker consuming logstore s with processor.
+func NewConsumerWorker(s *LogStore, config ConsumerConfig, processor ConsumerProcessor) *ConsumerWorker {
+	if config.GroupTimeout <= 0 {
+		config.GroupTimeout = DefaultConsumerGroupTimeout
+	}
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = DefaultConsumerHeartbeatInterval
+	}
+	if config.CheckpointInterval <= 0 {
+		config.CheckpointInterval = DefaultConsumerCheckpointInterval
+	}
+	if config.FetchInterval <= 0 {
+		config.FetchInterval = DefaultConsumerFetchInterval
+	}
+	if config.MaxFetchCount <= 0 {
+		config.MaxFetchCount = DefaultConsumerMaxFetchCount
+	}
+	if config.CursorPosition == "" {
+		config.CursorPosition = OffsetOldest
+	}
+
+	return &ConsumerWorker{
+		store:     s,
+		config:    config,
+		processor: processor,
+		shards:    make(map[int]*shardConsumer),
+		finished:  make(map[int]bool),
+	}
+}
+
+// Start creates the consumer group if needed and starts consuming.
+func (w *ConsumerWorker) Start() error {
+	err := w.store.CreateConsumerGroup(ConsumerGroup{
+		Name:    w.config.ConsumerGroup,
+		Timeout: int(w.config.GroupTimeout / time.Second),
+		InOrder: w.config.InOrder,
+	})
+	if slsErr, ok := err.(*Error); ok && slsErr.Code == "ConsumerGroupAlreadyExist" {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	w.commitCtx, w.abortCommits = context.WithCancel(context.Background())
+	go w.heartbeatLoop(ctx)
+	return nil
+}
+
+// Close stops consuming and saves the checkpoints of the shards.
+func (w *ConsumerWorker) Close() error {
+	return w.CloseWithContext(context.Background())
+}
+
+// CloseWithContext is like Close but gives up saving the checkpoints when
+// ctx is done, and returns the error of ctx. The shards are then consumed
+// again from their previous checkpoints by the next consumer.
+func (w *ConsumerWorker) CloseWithContext(ctx context.Context) error {
+	if w.cancel == nil {
+		return nil
+	}
+	stop := context.AfterFunc(ctx, w.abortCommits)
+	defer stop()
+	w.cancel()
+	<-w.done
+
+	w.mu.Lock()
+	var stopped []*shardConsumer
+	for shardID := range w.shards {
+		stopped = append(stopped, w.stopShard(shardID))
+	}
+	w.mu.Unlock()
+	waitShards(stopped)
+	if !stop() {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Shards returns the shards consumed by the worker.
+func (w *ConsumerWorker) Shards() []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.heldShards()
+}
+
+func (w *ConsumerWorker) heldShards() []int {
+	shardIDs := make([]int, 0, len(w.shards))
+	for shardID := range w.shards {
+		shardIDs = append(shardIDs, shardID)
+	}
+	return shardIDs
+}
+
+func (w *ConsumerWorker) heartbeatLoop(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		w.heartbeat(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// heartbeat starts consuming the shards newly assigned to the worker and
+// stops consuming the ones assigned to another consumer. It returns once
+// their checkpoints are saved, so that they're released by the next one.
+func (w *ConsumerWorker) heartbeat(ctx context.Context) {
+	w.mu.Lock()
+	held := w.heldShards()
+	w.mu.Unlock()
+
+	assigned, err := w.store.HeartBeatWithContext(ctx, w.config.ConsumerGroup, w.config.Consumer, held)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	keep := make(map[int]bool, len(assigned))
+	for _, shardID := range assigned {
+		keep[shardID] = true
+		if _, ok := w.shards[shardID]; !ok && !w.finished[shardID] && ctx.Err() == nil {
+			w.startShard(shardID)
+		}
+	}
+	var stopped []*shardConsumer
+	for shardID := range w.shards {
+		if !keep[shardID] {
+			stopped = append(stopped, w.stopShard(shardID))
+		}
+	}
+	for shardID := range w.finished {
+		if !keep[shardID] {
+			delete(w.finished, shardID)
+		}
+	}
+	w.mu.Unlock()
+	waitShards(stopped)
+}
+
+// startShard starts consuming a shard, w.mu must be held.
+func (w *ConsumerWorker) startShard(shardID int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sc := &shardConsumer{cancel: cancel, done: make(chan struct{})}
+	w.shards[shardID] = sc
+	go func() {
+		defer close(sc.done)
+		if w.consumeShard(ctx, shardID) {
+			w.finishShard(shardID, sc)
+		}
+	}()
+	w.reportShards()
+}
+
+// stopShard stops consuming a shard, w.mu must be held. The shard's
+// checkpoint is saved once the returned consumer is done, see waitShards.
+func (w *ConsumerWorker) stopShard(shardID int) *shardConsumer {
+	sc := w.shards[shardID]
+	sc.cancel()
+	delete(w.shards, shardID)
+	w.reportShards()
+	return sc
+}
+
+// finishShard releases a read-only shard consumed to its end by sc.
+func (w *ConsumerWorker) finishShard(shardID int, sc *shardConsumer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.shards[shardID] == sc {
+		delete(w.shards, shardID)
+		w.finished[shardID] = true
+		w.reportShards()
+	}
+}
+
+// waitShards waits for the shard consumers stopped to save their checkpoints.
+func waitShards(stopped []*shardConsumer) {
+	for _, sc := range stopped {
+		<-sc.done
+	}
+}
+
+// reportShards sets the MetricConsumerShards gauge, w.mu must be held.
+func (w *ConsumerWorker) reportShards() {
+	if m := w.store.project.Metrics; m != nil {
+		m.SetGauge(MetricConsumerShards, float64(len(w.shards)), "project", w.store.project.Name,
+			"logstore", w.store.Name, "consumer_group", w.config.ConsumerGroup, "consumer", w.config.Consumer)
+	}
+}
+
+// consumeShard pulls and processes the logs of a shard until ctx is done,
+// or until the end of the shard if it's read-only, then saves its
+// checkpoint. It returns true if the shard was consumed to its end.
+func (w *ConsumerWorker) consumeShard(ctx context.Context, shardID int) (finished bool) {
+	cursor, err := w.startCursor(ctx, shardID)
+	for err != nil {
+		if !sleepContext(ctx, w.config.FetchInterval) {
+			return false
+		}
+		cursor, err = w.startCursor(ctx, shardID)
+	}
+
+	committed, lastCommit := cursor, time.Now()
+	// A shard becomes read-only when it's split or merged, its status is
+	// checked again at most every CheckpointInterval at its end.
+	readOnly, lastCheck := w.readOnly(ctx, shardID), time.Now()
+	for ctx.Err() == nil {
+		gl, next, err := w.store.PullLogsWithContext(ctx, shardID, cursor, "", w.config.MaxFetchCount)
+		if err == nil && len(gl.LogGroups) > 0 {
+			err = w.processor.Process(shardID, gl)
+		}
+		if err == nil && len(gl.LogGroups) == 0 && next == cursor {
+			if !readOnly && time.Since(lastCheck) >= w.config.CheckpointInterval {
+				readOnly, lastCheck = w.readOnly(ctx, shardID), time.Now()
+			}
+			if readOnly {
+				finished = true
+				break
+			}
+		}
+		if err == nil {
+			cursor = next
+		}
+
+		if cursor != committed && time.Since(lastCommit) >= w.config.CheckpointInterval {
+			e := w.store.UpdateCheckpointWithContext(ctx, w.config.ConsumerGroup, w.config.Consumer,
+				shardID, cursor, false)
+			if e == nil {
+				committed, lastCommit = cursor, time.Now()
+			}
+		}
+
+		if err != nil || len(gl.LogGroups) == 0 {
+			sleepContext(ctx, w.config.FetchInterval)
+		}
+	}
+
+	// The shard may be assigned to another consumer already,
+	// which will resume from this checkpoint. The shard is lost anyway
+	// after GroupTimeout, so is the checkpoint.
+	if cursor != committed || finished {
+		commitCtx, cancel := context.WithTimeout(w.commitCtx, w.config.GroupTimeout)
+		w.store.UpdateCheckpointWithContext(commitCtx, w.config.ConsumerGroup, w.config.Consumer,
+			shardID, cursor, true)
+		cancel()
+	}
+	return finished
+}
+
+// readOnly tells whether a shard is read-only, i.e. gets no more logs.
+func (w *ConsumerWorker) readOnly(ctx context.Context, shardID int) bool {
+	shards, err := w.store.ListShardInfosWithContext(ctx)
+	if err != nil {
+		return false
+	}
+	for _, sh := range shards {
+		if sh.ShardID == shardID {
+			return !sh.ReadWrite()
+		}
+	}
+	return false
+}
+
+// startCursor returns the cursor a shard is consumed from: its checkpoint
+// in the consumer group if any, or ConsumerConfig.CursorPosition.
+func (w *ConsumerWorker) startCursor(ctx context.Context, shardID int) (string, error) {
+	checkpoints, err := w.store.GetCheckpointWithContext(ctx, w.config.ConsumerGroup)
+	if err != nil {
+		return "", err
+	}
+	for _, cp := range checkpoints {
+		if cp.ShardID == shardID && cp.Checkpoint != "" {
+			return cp.Checkpoint, nil
+		}
+	}
+	return w.store.GetCursorWithContext(ctx, shardID, w.config.CursorPosition)
+}
+
+// sleepContext waits for d, it returns false if ctx is done before.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
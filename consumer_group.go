@@ -0,0 +1,208 @@
+package sls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// ConsumerGroup defines consumer group
+type ConsumerGroup struct {
+	Name    string `json:"consumerGroup"`
+	Timeout int    `json:"timeout"` // Seconds without heartbeat before a consumer is removed
+	InOrder bool   `json:"order"`   // Consume the shards split or merged in order
+}
+
+// ConsumerGroupCheckpoint defines the checkpoint of a shard in a consumer group
+type ConsumerGroupCheckpoint struct {
+	ShardID    int    `json:"shard"`
+	Checkpoint string `json:"checkpoint"` // Cursor to resume consuming from
+	UpdateTime int64  `json:"updateTime"`
+	Consumer   string `json:"consumer"`
+}
+
+// CreateConsumerGroup creates a new consumer group in logstore s.
+func (s *LogStore) CreateConsumerGroup(cg ConsumerGroup) error {
+	return s.CreateConsumerGroupWithContext(context.Background(), cg)
+}
+
+// CreateConsumerGroupWithContext is like CreateConsumerGroup but uses ctx to cancel the request.
+func (s *LogStore) CreateConsumerGroupWithContext(ctx context.Context, cg ConsumerGroup) error {
+	body, err := json.Marshal(cg)
+	if err != nil {
+		return s.project.opError(err, "CreateConsumerGroup", nil)
+	}
+
+	h := map[string]string{
+		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
+		"Content-Type":      "application/json",
+	}
+	uri := fmt.Sprintf("/logstores/%v/consumergroups", s.Name)
+	_, err = request(ctx, s.project, "CreateConsumerGroup", "POST", uri, h, body)
+	return err
+}
+
+// UpdateConsumerGroup updates the timeout and order of a consumer group.
+func (s *LogStore) UpdateConsumerGroup(cg ConsumerGroup) error {
+	return s.UpdateConsumerGroupWithContext(context.Background(), cg)
+}
+
+// UpdateConsumerGroupWithContext is like UpdateConsumerGroup but uses ctx to cancel the request.
+func (s *LogStore) UpdateConsumerGroupWithContext(ctx context.Context, cg ConsumerGroup) error {
+	type Body struct {
+		Timeout int  `json:"timeout"`
+		InOrder bool `json:"order"`
+	}
+	body, err := json.Marshal(Body{
+		Timeout: cg.Timeout,
+		InOrder: cg.InOrder,
+	})
+	if err != nil {
+		return s.project.opError(err, "UpdateConsumerGroup", nil)
+	}
+
+	h := map[string]string{
+		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
+		"Content-Type":      "application/json",
+	}
+	uri := fmt.Sprintf("/logstores/%v/consumergroups/%v", s.Name, cg.Name)
+	_, err = request(ctx, s.project, "UpdateConsumerGroup", "PUT", uri, h, body)
+	return err
+}
+
+// DeleteConsumerGroup deletes a consumer group according by its name.
+func (s *LogStore) DeleteConsumerGroup(name string) error {
+	return s.DeleteConsumerGroupWithContext(context.Background(), name)
+}
+
+// DeleteConsumerGroupWithContext is like DeleteConsumerGroup but uses ctx to cancel the request.
+func (s *LogStore) DeleteConsumerGroupWithContext(ctx context.Context, name string) error {
+	h := map[string]string{
+		"x-log-bodyrawsize": "0",
+	}
+	uri := fmt.Sprintf("/logstores/%v/consumergroups/%v", s.Name, name)
+	_, err := request(ctx, s.project, "DeleteConsumerGroup", "DELETE", uri, h, nil)
+	return err
+}
+
+// ListConsumerGroup returns the consumer groups of logstore s.
+func (s *LogStore) ListConsumerGroup() ([]*ConsumerGroup, error) {
+	return s.ListConsumerGroupWithContext(context.Background())
+}
+
+// ListConsumerGroupWithContext is like ListConsumerGroup but uses ctx to cancel the request.
+func (s *LogStore) ListConsumerGroupWithContext(ctx context.Context) ([]*ConsumerGroup, error) {
+	h := map[string]string{
+		"x-log-bodyrawsize": "0",
+	}
+	uri := fmt.Sprintf("/logstores/%v/consumergroups", s.Name)
+	r, err := request(ctx, s.project, "ListConsumerGroup", "GET", uri, h, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, s.project.opError(err, "ListConsumerGroup", r)
+	}
+	var groups []*ConsumerGroup
+	if err = json.Unmarshal(buf, &groups); err != nil {
+		return nil, s.project.opError(err, "ListConsumerGroup", r)
+	}
+	return groups, nil
+}
+
+// HeartBeat keeps consumer alive in consumer group cgName. The heldShards
+// are the shards the consumer is consuming, the returned shardIDs are the
+// shards the server assigned to it.
+func (s *LogStore) HeartBeat(cgName, consumer string, heldShards []int) (shardIDs []int, err error) {
+	return s.HeartBeatWithContext(context.Background(), cgName, consumer, heldShards)
+}
+
+// HeartBeatWithContext is like HeartBeat but uses ctx to cancel the request.
+func (s *LogStore) HeartBeatWithContext(ctx context.Context, cgName, consumer string,
+	heldShards []int) (shardIDs []int, err error) {
+	if heldShards == nil {
+		heldShards = []int{}
+	}
+	body, err := json.Marshal(heldShards)
+	if err != nil {
+		return nil, s.project.opError(err, "HeartBeat", nil)
+	}
+
+	h := map[string]string{
+		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
+		"Content-Type":      "application/json",
+	}
+	uri := fmt.Sprintf("/logstores/%v/consumergroups/%v?type=heartbeat&consumer=%v", s.Name, cgName, consumer)
+	r, err := request(withIdempotent(ctx), s.project, "HeartBeat", "POST", uri, h, body)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, s.project.opError(err, "HeartBeat", r)
+	}
+	if err = json.Unmarshal(buf, &shardIDs); err != nil {
+		return nil, s.project.opError(err, "HeartBeat", r)
+	}
+	return shardIDs, nil
+}
+
+// UpdateCheckpoint saves the checkpoint of a shard consumed by consumer in
+// consumer group cgName. Unless forceSuccess, it fails if the shard isn't
+// assigned to the consumer.
+func (s *LogStore) UpdateCheckpoint(cgName, consumer string, shardID int, checkpoint string,
+	forceSuccess bool) error {
+	return s.UpdateCheckpointWithContext(context.Background(), cgName, consumer, shardID, checkpoint, forceSuccess)
+}
+
+// UpdateCheckpointWithContext is like UpdateCheckpoint but uses ctx to cancel the request.
+func (s *LogStore) UpdateCheckpointWithContext(ctx context.Context, cgName, consumer string, shardID int,
+	checkpoint string, forceSuccess bool) error {
+	body, err := json.Marshal(ConsumerGroupCheckpoint{
+		ShardID:    shardID,
+		Checkpoint: checkpoint,
+	})
+	if err != nil {
+		return s.project.opError(err, "UpdateCheckpoint", nil)
+	}
+
+	h := map[string]string{
+		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
+		"Content-Type":      "application/json",
+	}
+	uri := fmt.Sprintf("/logstores/%v/consumergroups/%v?type=checkpoint&consumer=%v&forceSuccess=%v",
+		s.Name, cgName, consumer, forceSuccess)
+	_, err = request(ctx, s.project, "UpdateCheckpoint", "POST", uri, h, body)
+	return err
+}
+
+// GetCheckpoint returns the checkpoints of the shards in consumer group cgName.
+func (s *LogStore) GetCheckpoint(cgName string) ([]*ConsumerGroupCheckpoint, error) {
+	return s.GetCheckpointWithContext(context.Background(), cgName)
+}
+
+// GetCheckpointWithContext is like GetCheckpoint but uses ctx to cancel the request.
+func (s *LogStore) GetCheckpointWithContext(ctx context.Context, cgName string) ([]*ConsumerGroupCheckpoint, error) {
+	h := map[string]string{
+		"x-log-bodyrawsize": "0",
+	}
+	uri := fmt.Sprintf("/logstores/%v/consumergroups/%v", s.Name, cgName)
+	r, err := request(ctx, s.project, "GetCheckpoint", "GET", uri, h, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, s.project.opError(err, "GetCheckpoint", r)
+	}
+	var checkpoints []*ConsumerGroupCheckpoint
+	if err = json.Unmarshal(buf, &checkpoints); err != nil {
+		return nil, s.project.opError(err, "GetCheckpoint", r)
+	}
+	return checkpoints, nil
+}
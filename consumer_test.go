@@ -0,0 +1,220 @@
+package sls_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	sls "github.com/galaxydi/go-loghub"
+	"github.com/galaxydi/go-loghub/slstest"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestConsumer(t *testing.T) {
+	suite.Run(t, new(ConsumerTestSuite))
+}
+
+type ConsumerTestSuite struct {
+	suite.Suite
+	server *slstest.Server
+	store  *sls.LogStore
+
+	mu       sync.Mutex
+	consumed map[string]int // Number of times each log was processed
+}
+
+func (s *ConsumerTestSuite) SetupTest() {
+	s.server, s.store = slstest.NewLogStore(s.T(), 4)
+	s.consumed = make(map[string]int)
+}
+
+func (s *ConsumerTestSuite) Process(shardID int, gl *sls.LogGroupList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, lg := range gl.LogGroups {
+		for _, l := range lg.Logs {
+			s.consumed[l.Contents[0].GetValue()]++
+		}
+	}
+	return nil
+}
+
+func (s *ConsumerTestSuite) putLogs(from, to int) {
+	for i := from; i < to; i++ {
+		lg := &sls.LogGroup{
+			Logs: []*sls.Log{{
+				Time: proto.Uint32(uint32(time.Now().Unix())),
+				Contents: []*sls.LogContent{{
+					Key:   proto.String("index"),
+					Value: proto.String(fmt.Sprint(i)),
+				}},
+			}},
+		}
+		s.Nil(s.store.PutLogs(lg))
+	}
+}
+
+// waitFor waits until cond is true, it returns false on timeout.
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func (s *ConsumerTestSuite) consumedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.consumed)
+}
+
+func (s *ConsumerTestSuite) newWorker(name string) *sls.ConsumerWorker {
+	return sls.NewConsumerWorker(s.store, sls.ConsumerConfig{
+		ConsumerGroup:      "test-group",
+		Consumer:           name,
+		HeartbeatInterval:  20 * time.Millisecond,
+		CheckpointInterval: 20 * time.Millisecond,
+		FetchInterval:      10 * time.Millisecond,
+	}, s)
+}
+
+func (s *ConsumerTestSuite) TestConsume() {
+	s.putLogs(0, 20)
+	w := s.newWorker("consumer-1")
+	s.Nil(w.Start())
+	s.True(waitFor(func() bool { return len(w.Shards()) == 4 }))
+	s.True(waitFor(func() bool { return s.consumedCount() == 20 }))
+	s.Nil(w.Close())
+
+	// The checkpoints are saved on close.
+	checkpoints, err := s.store.GetCheckpoint("test-group")
+	s.Nil(err)
+	s.Len(checkpoints, 4)
+	for _, cp := range checkpoints {
+		end, err := s.store.GetCursor(cp.ShardID, sls.OffsetNewest)
+		s.Nil(err)
+		s.Equal(end, cp.Checkpoint)
+	}
+
+	// A new worker resumes from the checkpoints.
+	s.putLogs(20, 30)
+	w = s.newWorker("consumer-1")
+	s.Nil(w.Start())
+	s.True(waitFor(func() bool { return s.consumedCount() == 30 }))
+	s.Nil(w.Close())
+	for value, n := range s.consumed {
+		s.Equal(1, n, value)
+	}
+}
+
+func (s *ConsumerTestSuite) TestCloseWithContext() {
+	// The checkpoints hang until their context is done.
+	project, _ := s.server.NewProject(slstest.ProjectName).WithInterceptors(
+		func(ctx context.Context, req *sls.Request, next sls.Handler) (*http.Response, error) {
+			if req.Operation == "UpdateCheckpoint" {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return next(ctx, req)
+		})
+	s.store, _ = project.GetLogStore(slstest.LogStoreName)
+
+	s.putLogs(0, 20)
+	w := s.newWorker("consumer-1")
+	s.Nil(w.Start())
+	s.True(waitFor(func() bool { return s.consumedCount() == 20 }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	s.Equal(context.DeadlineExceeded, w.CloseWithContext(ctx))
+	s.True(time.Since(start) < 2*time.Second)
+}
+
+func (s *ConsumerTestSuite) TestRebalance() {
+	w1 := s.newWorker("consumer-1")
+	s.Nil(w1.Start())
+	defer w1.Close()
+	s.True(waitFor(func() bool { return len(w1.Shards()) == 4 }))
+
+	w2 := s.newWorker("consumer-2")
+	s.Nil(w2.Start())
+	defer w2.Close()
+	s.True(waitFor(func() bool { return len(w1.Shards()) == 2 && len(w2.Shards()) == 2 }))
+
+	shards := append(w1.Shards(), w2.Shards()...)
+	sort.Ints(shards)
+	s.Equal([]int{0, 1, 2, 3}, shards)
+
+	s.putLogs(0, 20)
+	s.True(waitFor(func() bool { return s.consumedCount() == 20 }))
+}
+
+func (s *ConsumerTestSuite) TestReleaseReadOnlyShard() {
+	s.putLogs(0, 20)
+	_, err := s.store.SplitShard(0, "20000000000000000000000000000000")
+	s.Nil(err)
+	s.putLogs(20, 30)
+
+	w := s.newWorker("consumer-1")
+	s.Nil(w.Start())
+	defer w.Close()
+	s.True(waitFor(func() bool { return s.consumedCount() == 30 }))
+
+	// Shard 0 is consumed to its end, its checkpoint is saved and it's
+	// released while the other shards are still consumed.
+	s.True(waitFor(func() bool {
+		shards := w.Shards()
+		sort.Ints(shards)
+		return fmt.Sprint(shards) == "[1 2 3 4 5]"
+	}))
+	end, err := s.store.GetCursor(0, sls.OffsetNewest)
+	s.Nil(err)
+	checkpoints, err := s.store.GetCheckpoint("test-group")
+	s.Nil(err)
+	var checkpoint string
+	for _, cp := range checkpoints {
+		if cp.ShardID == 0 {
+			checkpoint = cp.Checkpoint
+		}
+	}
+	s.Equal(end, checkpoint)
+}
+
+func (s *ConsumerTestSuite) TestConsumerGroupAPI() {
+	s.Nil(s.store.CreateConsumerGroup(sls.ConsumerGroup{Name: "test-group", Timeout: 60}))
+	err := s.store.CreateConsumerGroup(sls.ConsumerGroup{Name: "test-group", Timeout: 60})
+	s.NotNil(err)
+
+	s.Nil(s.store.UpdateConsumerGroup(sls.ConsumerGroup{Name: "test-group", Timeout: 30, InOrder: true}))
+	groups, err := s.store.ListConsumerGroup()
+	s.Nil(err)
+	s.Equal([]*sls.ConsumerGroup{{Name: "test-group", Timeout: 30, InOrder: true}}, groups)
+
+	shards, err := s.store.HeartBeat("test-group", "consumer-1", nil)
+	s.Nil(err)
+	s.Equal([]int{0, 1, 2, 3}, shards)
+
+	cursor, err := s.store.GetCursor(1, sls.OffsetNewest)
+	s.Nil(err)
+	s.Nil(s.store.UpdateCheckpoint("test-group", "consumer-1", 1, cursor, false))
+	s.NotNil(s.store.UpdateCheckpoint("test-group", "consumer-2", 1, cursor, false))
+	checkpoints, err := s.store.GetCheckpoint("test-group")
+	s.Nil(err)
+	s.Equal(cursor, checkpoints[1].Checkpoint)
+	s.Equal("consumer-1", checkpoints[1].Consumer)
+
+	s.Nil(s.store.DeleteConsumerGroup("test-group"))
+	groups, err = s.store.ListConsumerGroup()
+	s.Nil(err)
+	s.Len(groups, 0)
+}
@@ -0,0 +1,277 @@
+package sls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials defines the access key used to sign requests.
+type Credentials struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SecurityToken   string    // STS token, empty for the access keys of an account or RAM user
+	Expiration      time.Time // Zero means the credentials never expire
+}
+
+// validAt tells whether c is set and not expired at time t.
+func (c Credentials) validAt(t time.Time) bool {
+	return c.AccessKeyID != "" && (c.Expiration.IsZero() || t.Before(c.Expiration))
+}
+
+// CredentialsProvider provides the credentials of a project.
+// Credentials is called before signing every request, including each
+// retry, so it must be safe for concurrent use and should be cheap.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentialsProvider provides fixed credentials.
+type StaticCredentialsProvider struct {
+	creds Credentials
+}
+
+// NewStaticCredentialsProvider creates a provider of fixed credentials,
+// securityToken may be empty.
+func NewStaticCredentialsProvider(accessKeyID, accessKeySecret, securityToken string) *StaticCredentialsProvider {
+	return &StaticCredentialsProvider{
+		creds: Credentials{
+			AccessKeyID:     accessKeyID,
+			AccessKeySecret: accessKeySecret,
+			SecurityToken:   securityToken,
+		},
+	}
+}
+
+// Credentials implements CredentialsProvider.
+func (p *StaticCredentialsProvider) Credentials(ctx context.Context) (Credentials, error) {
+	return p.creds, nil
+}
+
+// Environment variables read by EnvCredentialsProvider.
+const (
+	EnvAccessKeyID     = "ALIBABA_CLOUD_ACCESS_KEY_ID"
+	EnvAccessKeySecret = "ALIBABA_CLOUD_ACCESS_KEY_SECRET"
+	EnvSecurityToken   = "ALIBABA_CLOUD_SECURITY_TOKEN"
+)
+
+// EnvCredentialsProvider provides the credentials set in the environment
+// variables EnvAccessKeyID, EnvAccessKeySecret and optionally EnvSecurityToken.
+// The variables are read on every call.
+type EnvCredentialsProvider struct{}
+
+// Credentials implements CredentialsProvider.
+func (EnvCredentialsProvider) Credentials(ctx context.Context) (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv(EnvAccessKeyID),
+		AccessKeySecret: os.Getenv(EnvAccessKeySecret),
+		SecurityToken:   os.Getenv(EnvSecurityToken),
+	}
+	if creds.AccessKeyID == "" || creds.AccessKeySecret == "" {
+		return Credentials{}, fmt.Errorf("%v or %v isn't set", EnvAccessKeyID, EnvAccessKeySecret)
+	}
+	return creds, nil
+}
+
+// credentialsJSON is the JSON document of the credentials in a file or
+// returned by the ECS metadata service.
+type credentialsJSON struct {
+	Code            string
+	AccessKeyID     string `json:"AccessKeyId"`
+	AccessKeySecret string
+	SecurityToken   string
+	Expiration      string // RFC 3339, e.g. "2017-11-01T05:20:01Z"
+}
+
+func (c *credentialsJSON) credentials() (Credentials, error) {
+	if c.AccessKeyID == "" || c.AccessKeySecret == "" {
+		return Credentials{}, fmt.Errorf("AccessKeyId or AccessKeySecret is missing")
+	}
+	creds := Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		AccessKeySecret: c.AccessKeySecret,
+		SecurityToken:   c.SecurityToken,
+	}
+	if c.Expiration != "" {
+		t, err := time.Parse(time.RFC3339, c.Expiration)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("bad Expiration %q: %v", c.Expiration, err)
+		}
+		creds.Expiration = t
+	}
+	return creds, nil
+}
+
+// FileCredentialsProvider provides the credentials stored in a JSON file
+// like {"AccessKeyId": "...", "AccessKeySecret": "...", "SecurityToken": "...",
+// "Expiration": "2017-11-01T05:20:01Z"}, where SecurityToken and Expiration
+// are optional. The file is read again whenever it changes, so another
+// process, e.g. a sidecar rotating STS tokens, can update it at any time.
+type FileCredentialsProvider struct {
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	creds   Credentials
+}
+
+// NewFileCredentialsProvider creates a provider of the credentials stored in file path.
+func NewFileCredentialsProvider(path string) *FileCredentialsProvider {
+	return &FileCredentialsProvider{Path: path}
+}
+
+// Credentials implements CredentialsProvider.
+func (p *FileCredentialsProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if p.creds.AccessKeyID != "" && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return p.creds, nil
+	}
+
+	buf, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var c credentialsJSON
+	if err = json.Unmarshal(buf, &c); err != nil {
+		return Credentials{}, fmt.Errorf("bad credentials file %v: %v", p.Path, err)
+	}
+	creds, err := c.credentials()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("bad credentials file %v: %v", p.Path, err)
+	}
+	p.creds, p.modTime, p.size = creds, info.ModTime(), info.Size()
+	return creds, nil
+}
+
+// Defaults of ECSRAMRoleCredentialsProvider.
+const (
+	DefaultECSMetadataEndpoint = "http://100.100.100.200"
+	DefaultRefreshBefore       = 5 * time.Minute
+)
+
+// metadataHTTPClient sends the requests to the ECS metadata service,
+// which is in the same host so it should answer quickly.
+var metadataHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+// ECSRAMRoleCredentialsProvider provides the STS credentials of the RAM role
+// attached to the ECS instance the program runs on. The credentials are
+// fetched from the instance metadata service, cached, and fetched again
+// RefreshBefore their expiration.
+type ECSRAMRoleCredentialsProvider struct {
+	// RoleName is the name of the RAM role, if empty, the role attached to
+	// the instance is looked up on the first call.
+	RoleName string
+
+	Endpoint      string        // URL of the metadata service, empty means DefaultECSMetadataEndpoint
+	RefreshBefore time.Duration // Zero means DefaultRefreshBefore
+	HTTPClient    *http.Client  // Nil means metadataHTTPClient
+
+	mu    sync.Mutex
+	creds Credentials
+}
+
+// NewECSRAMRoleCredentialsProvider creates a provider of the credentials of RAM role roleName.
+func NewECSRAMRoleCredentialsProvider(roleName string) *ECSRAMRoleCredentialsProvider {
+	return &ECSRAMRoleCredentialsProvider{RoleName: roleName}
+}
+
+// Credentials implements CredentialsProvider. If a refresh fails while the
+// cached credentials haven't expired yet, the cached credentials are returned.
+func (p *ECSRAMRoleCredentialsProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	refreshBefore := p.RefreshBefore
+	if refreshBefore <= 0 {
+		refreshBefore = DefaultRefreshBefore
+	}
+	now := time.Now()
+	if p.creds.validAt(now.Add(refreshBefore)) {
+		return p.creds, nil
+	}
+
+	creds, err := p.fetch(ctx)
+	if err != nil {
+		if p.creds.validAt(now) {
+			return p.creds, nil
+		}
+		return Credentials{}, err
+	}
+	p.creds = creds
+	return creds, nil
+}
+
+func (p *ECSRAMRoleCredentialsProvider) fetch(ctx context.Context) (Credentials, error) {
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultECSMetadataEndpoint
+	}
+	urlStr := strings.TrimRight(endpoint, "/") + "/latest/meta-data/ram/security-credentials/"
+
+	if p.RoleName == "" {
+		buf, err := p.get(ctx, urlStr)
+		if err != nil {
+			return Credentials{}, err
+		}
+		p.RoleName = strings.TrimSpace(string(buf))
+		if p.RoleName == "" {
+			return Credentials{}, fmt.Errorf("no RAM role is attached to the instance")
+		}
+	}
+
+	buf, err := p.get(ctx, urlStr+p.RoleName)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var c credentialsJSON
+	if err = json.Unmarshal(buf, &c); err != nil {
+		return Credentials{}, fmt.Errorf("bad credentials of RAM role %v: %v", p.RoleName, err)
+	}
+	if c.Code != "" && c.Code != "Success" {
+		return Credentials{}, fmt.Errorf("failed to get credentials of RAM role %v: %v", p.RoleName, c.Code)
+	}
+	creds, err := c.credentials()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("bad credentials of RAM role %v: %v", p.RoleName, err)
+	}
+	return creds, nil
+}
+
+// get returns the body of a metadata service URL.
+func (p *ECSRAMRoleCredentialsProvider) get(ctx context.Context, urlStr string) ([]byte, error) {
+	client := p.HTTPClient
+	if client == nil {
+		client = metadataHTTPClient
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %v: %v", urlStr, resp.Status)
+	}
+	return buf, nil
+}
@@ -0,0 +1,229 @@
+package sls
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStaticCredentialsProvider(t *testing.T) {
+	p := NewStaticCredentialsProvider("id", "secret", "token")
+	creds, err := p.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "id" || creds.AccessKeySecret != "secret" || creds.SecurityToken != "token" {
+		t.Errorf("bad credentials:%+v", creds)
+	}
+}
+
+func TestEnvCredentialsProvider(t *testing.T) {
+	for _, k := range []string{EnvAccessKeyID, EnvAccessKeySecret, EnvSecurityToken} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Unsetenv(k)
+	}
+
+	var p EnvCredentialsProvider
+	if _, err := p.Credentials(context.Background()); err == nil {
+		t.Errorf("expected an error without environment variables")
+	}
+
+	os.Setenv(EnvAccessKeyID, "id")
+	os.Setenv(EnvAccessKeySecret, "secret")
+	creds, err := p.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "id" || creds.AccessKeySecret != "secret" || creds.SecurityToken != "" {
+		t.Errorf("bad credentials:%+v", creds)
+	}
+}
+
+func TestFileCredentialsProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sls-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials.json")
+
+	write := func(token string, modTime time.Time) {
+		data := fmt.Sprintf(`{"AccessKeyId": "id", "AccessKeySecret": "secret", "SecurityToken": %q,
+			"Expiration": "2030-01-01T00:00:00Z"}`, token)
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewFileCredentialsProvider(path)
+	if _, err := p.Credentials(context.Background()); err == nil {
+		t.Errorf("expected an error without file")
+	}
+
+	now := time.Now()
+	write("token-1", now)
+	creds, err := p.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.SecurityToken != "token-1" || creds.Expiration.Year() != 2030 {
+		t.Errorf("bad credentials:%+v", creds)
+	}
+
+	write("token-2", now.Add(time.Second))
+	if creds, _ = p.Credentials(context.Background()); creds.SecurityToken != "token-2" {
+		t.Errorf("expected the updated token, got %v", creds.SecurityToken)
+	}
+}
+
+// metadataServer emulates the ECS metadata service of role "test-role".
+type metadataServer struct {
+	mu         sync.Mutex
+	fetches    int
+	fail       bool
+	expiration time.Time
+}
+
+func (s *metadataServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.fail:
+		w.WriteHeader(http.StatusInternalServerError)
+	case r.URL.Path == "/latest/meta-data/ram/security-credentials/":
+		fmt.Fprint(w, "test-role")
+	case r.URL.Path == "/latest/meta-data/ram/security-credentials/test-role":
+		s.fetches++
+		fmt.Fprintf(w, `{"Code": "Success", "AccessKeyId": "id", "AccessKeySecret": "secret",
+			"SecurityToken": "token-%v", "Expiration": %q}`, s.fetches, s.expiration.Format(time.RFC3339))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestECSRAMRoleCredentialsProvider(t *testing.T) {
+	ms := &metadataServer{expiration: time.Now().Add(time.Hour)}
+	server := httptest.NewServer(ms)
+	defer server.Close()
+
+	p := &ECSRAMRoleCredentialsProvider{Endpoint: server.URL}
+	for i := 0; i < 3; i++ {
+		creds, err := p.Credentials(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.SecurityToken != "token-1" {
+			t.Errorf("expected the cached token, got %v", creds.SecurityToken)
+		}
+	}
+	if p.RoleName != "test-role" {
+		t.Errorf("bad role name:%v", p.RoleName)
+	}
+
+	// The credentials are refreshed before they expire.
+	ms.mu.Lock()
+	ms.expiration = time.Now().Add(time.Minute)
+	ms.mu.Unlock()
+	p.RefreshBefore = 30 * time.Minute
+	p.creds.Expiration = time.Now().Add(10 * time.Minute)
+	creds, err := p.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.SecurityToken != "token-2" {
+		t.Errorf("expected a refreshed token, got %v", creds.SecurityToken)
+	}
+
+	// The cached credentials are used while the service fails.
+	ms.mu.Lock()
+	ms.fail = true
+	ms.mu.Unlock()
+	if creds, err = p.Credentials(context.Background()); err != nil || creds.SecurityToken != "token-2" {
+		t.Errorf("expected the cached token, got %v, %v", creds.SecurityToken, err)
+	}
+	p.creds.Expiration = time.Now().Add(-time.Second)
+	if _, err = p.Credentials(context.Background()); err == nil {
+		t.Errorf("expected an error with expired credentials")
+	}
+}
+
+// rotatingProvider returns a new security token on every call.
+type rotatingProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *rotatingProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return Credentials{
+		AccessKeyID:     "mockAccessKeyID",
+		AccessKeySecret: "mockAccessKeySecret",
+		SecurityToken:   fmt.Sprintf("token-%v", p.calls),
+	}, nil
+}
+
+func TestRequestUsesCredentialsProvider(t *testing.T) {
+	rt := &recordTransport{}
+	p, _ := NewLogProject("test-credentials", "cn-hangzhou.log.aliyuncs.com", "", "")
+	p.WithHTTPClient(&http.Client{Transport: rt})
+	p.WithCredentialsProvider(&rotatingProvider{})
+
+	for i := 1; i <= 2; i++ {
+		if _, err := p.ListLogStore(); err != nil {
+			t.Fatal(err)
+		}
+		req := rt.reqs[len(rt.reqs)-1]
+		if token := req.Header.Get("x-acs-security-token"); token != fmt.Sprintf("token-%v", i) {
+			t.Errorf("bad security token:%v", token)
+		}
+		if auth := req.Header.Get("Authorization"); auth[:len("SLS mockAccessKeyID:")] != "SLS mockAccessKeyID:" {
+			t.Errorf("bad authorization:%v", auth)
+		}
+	}
+}
+
+func TestWithTokenWhileSending(t *testing.T) {
+	p, _ := NewLogProject("test-credentials", "cn-hangzhou.log.aliyuncs.com",
+		"mockAccessKeyID", "mockAccessKeySecret")
+	p.WithHTTPClient(&http.Client{Transport: &lockedTransport{}})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			p.WithToken(fmt.Sprintf("token-%v", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			p.ListLogStore()
+		}
+	}()
+	wg.Wait()
+}
+
+// lockedTransport is a recordTransport safe for concurrent use.
+type lockedTransport struct {
+	mu sync.Mutex
+	recordTransport
+}
+
+func (t *lockedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.recordTransport.RoundTrip(req)
+}
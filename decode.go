@@ -0,0 +1,335 @@
+package sls
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// DecodeLog decodes the contents of log l into v, a pointer to a struct or
+// to a map with string keys. The struct fields are matched by their sls
+// tags like with Encoder, and the field tagged time gets the time of l:
+//
+//	At     time.Time `sls:",time"`   // Log.Time and Log.TimeNs, or an int of seconds
+//	Topic  string    `sls:",topic"`  // topic of the log group
+//	Source string    `sls:",source"` // source of the log group
+//	Code   int       `sls:"code"`    // content "code" parsed as an int
+//
+// The contents are parsed according to the types of the fields: ints,
+// uints, floats, bools, time.Time in RFC 3339 or in seconds, and the types
+// implementing encoding.TextUnmarshaler. The contents of slices, maps and
+// structs are JSON, or the fields of a struct and the entries of a map are
+// the contents with dotted keys, e.g. "request.method". The fields without
+// contents are left unchanged.
+func DecodeLog(l *Log, v interface{}) error {
+	return decode(logValues(l, nil), v)
+}
+
+// DecodeLogGroup decodes the logs of log group lg into v, a pointer to a
+// slice of structs or of pointers to structs, see DecodeLog. The fields
+// tagged topic and source get the topic and source of lg, and its tags are
+// contents prefixed with TagKeyPrefix like in query results.
+func DecodeLogGroup(lg *LogGroup, v interface{}) error {
+	return decodeSlice(len(lg.Logs), func(i int) map[string]string {
+		return logValues(lg.Logs[i], lg)
+	}, v)
+}
+
+// DecodeRow decodes row, a log returned by GetLogs, into v like DecodeLog.
+// The fields tagged time, topic and source get the TimeKey, TopicKey and
+// SourceKey fields of row, a time.Time with the TimeNsPartKey field too.
+func DecodeRow(row map[string]string, v interface{}) error {
+	return decode(row, v)
+}
+
+// DecodeRows decodes rows, the logs returned by GetLogs, into v, a pointer
+// to a slice of structs or of pointers to structs, see DecodeRow.
+func DecodeRows(rows []map[string]string, v interface{}) error {
+	return decodeSlice(len(rows), func(i int) map[string]string {
+		return rows[i]
+	}, v)
+}
+
+// Decode decodes the logs of resp into v, see DecodeRows.
+func (resp *GetLogsResponse) Decode(v interface{}) error {
+	return DecodeRows(resp.Logs, v)
+}
+
+// logValues returns the contents of log l, with its time and the topic,
+// source and tags of its log group lg if not nil.
+func logValues(l *Log, lg *LogGroup) map[string]string {
+	values := make(map[string]string, len(l.Contents)+3)
+	for _, c := range l.Contents {
+		values[c.GetKey()] = c.GetValue()
+	}
+	values[TimeKey] = strconv.FormatUint(uint64(l.GetTime()), 10)
+	if l.TimeNs != nil {
+		values[TimeNsPartKey] = strconv.FormatUint(uint64(l.GetTimeNs()), 10)
+	}
+	if lg != nil {
+		values[TopicKey] = lg.GetTopic()
+		values[SourceKey] = lg.GetSource()
+		for _, t := range lg.LogTags {
+			values[TagKeyPrefix+t.GetKey()] = t.GetValue()
+		}
+	}
+	return values
+}
+
+func decode(values map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return NewClientError(fmt.Sprintf("can't decode a log into %T", v))
+	}
+	if err := decodeInto(values, rv.Elem()); err != nil {
+		return NewClientError(err.Error())
+	}
+	return nil
+}
+
+func decodeSlice(n int, values func(i int) map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return NewClientError(fmt.Sprintf("can't decode logs into %T", v))
+	}
+	slice := reflect.MakeSlice(rv.Elem().Type(), n, n)
+	for i := 0; i < n; i++ {
+		if err := decodeInto(values(i), slice.Index(i)); err != nil {
+			return NewClientError(fmt.Sprintf("log %v: %v", i, err))
+		}
+	}
+	rv.Elem().Set(slice)
+	return nil
+}
+
+// decodeInto decodes values into v, a struct or a map with string keys or
+// a pointer to one.
+func decodeInto(values map[string]string, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Struct && !isTextTarget(v):
+		return decodeStruct(values, "", v)
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		return decodeMap(values, "", v)
+	}
+	return fmt.Errorf("can't decode a log into %v", v.Type())
+}
+
+// isTextTarget tells whether v is decoded with UnmarshalText.
+func isTextTarget(v reflect.Value) bool {
+	return v.Type() != timeType && reflect.PtrTo(v.Type()).Implements(textUnmarshalerType)
+}
+
+// hasPrefix tells whether a key of values starts with prefix.
+func hasPrefix(values map[string]string, prefix string) bool {
+	for k := range values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeStruct(values map[string]string, prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, skip := parseField(f)
+		if skip {
+			continue
+		}
+		fv := v.Field(i)
+		if tag.special != "" {
+			if s, ok := values[tag.special]; ok {
+				if err := decodeValue(fv, s); err != nil {
+					return fmt.Errorf("field %v: %v", f.Name, err)
+				}
+				if tag.special == TimeKey {
+					if err := addTimeNs(fv, values); err != nil {
+						return fmt.Errorf("field %v: %v", f.Name, err)
+					}
+				}
+			}
+			continue
+		}
+		key := tag.key
+		if key == "" {
+			// An embedded struct without a tag, its fields are ours. Like
+			// encoding/json, the other unexported embedded types are skipped.
+			if f.PkgPath != "" && (fv.Kind() != reflect.Struct || isTextTarget(fv)) {
+				continue
+			}
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType && !isTextTarget(reflect.Zero(ft)) {
+				if err := decodeInto(values, fv); err != nil {
+					return err
+				}
+				continue
+			}
+			key = f.Name
+		}
+		if err := decodeField(values, prefix+key, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMap(values map[string]string, prefix string, v reflect.Value) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	for k, s := range values {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		e := reflect.New(v.Type().Elem()).Elem()
+		if err := decodeValue(e, s); err != nil {
+			return fmt.Errorf("%v: %v", k, err)
+		}
+		v.SetMapIndex(reflect.ValueOf(strings.TrimPrefix(k, prefix)).Convert(v.Type().Key()), e)
+	}
+	return nil
+}
+
+// decodeField decodes the content with key into v, or the contents with
+// dotted keys starting with key if v is a struct or a map.
+func decodeField(values map[string]string, key string, v reflect.Value) error {
+	if s, ok := values[key]; ok {
+		if err := decodeValue(v, s); err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		}
+		return nil
+	}
+
+	prefix := key + "."
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	nested := t.Kind() == reflect.Struct && t != timeType && !isTextTarget(reflect.Zero(t)) ||
+		t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+	if !nested || !hasPrefix(values, prefix) {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(t))
+		}
+		v = v.Elem()
+	}
+	if t.Kind() == reflect.Map {
+		return decodeMap(values, prefix, v)
+	}
+	return decodeStruct(values, prefix, v)
+}
+
+// decodeValue parses s into v according to the type of v.
+func decodeValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		e := reflect.New(v.Type().Elem())
+		if err := decodeValue(e.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if isTextTarget(v) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("can't decode into %v", v.Type())
+		}
+		v.Set(reflect.ValueOf(s))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	case reflect.Array, reflect.Map, reflect.Struct:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	default:
+		return fmt.Errorf("can't decode into %v", v.Type())
+	}
+	return nil
+}
+
+// addTimeNs adds the TimeNsPartKey value of values to v if it's a time.Time
+// without nanoseconds, or a pointer to one.
+func addTimeNs(v reflect.Value, values map[string]string) error {
+	s, ok := values[TimeNsPartKey]
+	v = indirect(v)
+	if !ok || !v.IsValid() || v.Type() != timeType {
+		return nil
+	}
+	t := v.Interface().(time.Time)
+	if t.Nanosecond() != 0 {
+		return nil
+	}
+	ns, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || ns >= uint64(time.Second) {
+		return fmt.Errorf("bad %v:%v", TimeNsPartKey, s)
+	}
+	v.Set(reflect.ValueOf(t.Add(time.Duration(ns))))
+	return nil
+}
+
+// parseTime parses s, a number of seconds or a time in RFC 3339.
+func parseTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
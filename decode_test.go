@@ -0,0 +1,156 @@
+package sls
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+func TestDecodeEncodedLog(t *testing.T) {
+	ev := &encodeEvent{
+		encodeMeta: encodeMeta{Host: "host-1"},
+		At:         time.Unix(1500000000, 0),
+		Level:      "info",
+		Code:       -200,
+		Latency:    0.25,
+		OK:         true,
+		Tags:       []string{"a", "b"},
+		Body:       []byte("body"),
+		IP:         net.IPv4(10, 0, 0, 1),
+		Request: &encodeRequest{
+			Method:  "GET",
+			Headers: map[string]string{"b": "2", "a": "1"},
+		},
+		Untagged: 7,
+	}
+	l, err := EncodeLog(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &encodeEvent{}
+	if err := DecodeLog(l, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ev, decoded) {
+		t.Errorf("unexpected log %+v", decoded)
+	}
+}
+
+func TestDecodeEncodedTimeNs(t *testing.T) {
+	at := time.Unix(1500000000, 123456789)
+	l, err := EncodeLog(&struct {
+		At time.Time `sls:",time"`
+	}{At: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.GetTime() != 1500000000 || l.GetTimeNs() != 123456789 {
+		t.Errorf("unexpected time %v %v", l.GetTime(), l.GetTimeNs())
+	}
+
+	// The logs pulled and the query results keep the nanoseconds.
+	data, err := (&LogGroup{Logs: []*Log{l}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg := &LogGroup{}
+	if err := lg.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	ev := &struct {
+		At  time.Time  `sls:",time"`
+		Ptr *time.Time `sls:",time"`
+		Sec int64      `sls:",time"`
+	}{}
+	if err := DecodeLog(lg.Logs[0], ev); err != nil {
+		t.Fatal(err)
+	}
+	if !ev.At.Equal(at) || ev.Ptr == nil || !ev.Ptr.Equal(at) || ev.Sec != 1500000000 {
+		t.Errorf("unexpected times %v %v %v", ev.At, ev.Ptr, ev.Sec)
+	}
+	row := map[string]string{TimeKey: "1500000000", TimeNsPartKey: "123456789"}
+	ev.At = time.Time{}
+	if err := DecodeRow(row, ev); err != nil {
+		t.Fatal(err)
+	}
+	if !ev.At.Equal(at) {
+		t.Errorf("unexpected time %v", ev.At)
+	}
+}
+
+type decodeRow struct {
+	At     time.Time `sls:",time"`
+	Topic  string    `sls:",topic"`
+	Source string    `sls:",source"`
+	Count  *int64    `sls:"count"`
+	Ratio  float32   `sls:"ratio"`
+	Items  []int     `sls:"items"`
+	Extra  struct {
+		Name string `json:"name"`
+	} `sls:"extra"`
+	Any interface{} `sls:"any"`
+}
+
+func TestDecodeRows(t *testing.T) {
+	resp := &GetLogsResponse{Logs: []map[string]string{
+		{TimeKey: "1500000000", TopicKey: "topic", SourceKey: "10.0.0.1", "count": "3", "ratio": "0.5"},
+		{TimeKey: "1500000001", "items": "[1,2]", "extra": `{"name":"n"}`, "any": "x"},
+	}}
+	var rows []decodeRow
+	if err := resp.Decode(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+	r := rows[0]
+	if !r.At.Equal(time.Unix(1500000000, 0)) || r.Topic != "topic" || r.Source != "10.0.0.1" ||
+		r.Count == nil || *r.Count != 3 || r.Ratio != 0.5 {
+		t.Errorf("unexpected row %+v", r)
+	}
+	r = rows[1]
+	if r.Count != nil || !reflect.DeepEqual(r.Items, []int{1, 2}) || r.Extra.Name != "n" || r.Any != "x" {
+		t.Errorf("unexpected row %+v", r)
+	}
+
+	if err := DecodeRows(resp.Logs, rows); err == nil {
+		t.Error("no error decoding into a slice")
+	}
+	var row decodeRow
+	if err := DecodeRow(map[string]string{"count": "three"}, &row); err == nil {
+		t.Error("no error decoding an invalid int")
+	} else if slsErr, ok := err.(*Error); !ok || slsErr.Code != "ClientError" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDecodeLogGroup(t *testing.T) {
+	lg := &LogGroup{
+		Topic:  proto.String("topic"),
+		Source: proto.String("10.0.0.1"),
+		Logs: []*Log{
+			{Time: proto.Uint32(1500000000), Contents: []*LogContent{{Key: proto.String("count"), Value: proto.String("1")}}},
+			{Time: proto.Uint32(1500000001), Contents: []*LogContent{{Key: proto.String("count"), Value: proto.String("2")}}},
+		},
+	}
+	var rows []*decodeRow
+	if err := DecodeLogGroup(lg, &rows); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range rows {
+		if r.Topic != "topic" || r.Source != "10.0.0.1" || r.At.Unix() != int64(1500000000+i) || *r.Count != int64(i+1) {
+			t.Errorf("unexpected row %+v", r)
+		}
+	}
+
+	values := make(map[string]string)
+	if err := DecodeLog(lg.Logs[0], &values); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, map[string]string{"count": "1", TimeKey: "1500000000"}) {
+		t.Errorf("unexpected values %v", values)
+	}
+}
@@ -0,0 +1,325 @@
+package sls
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// Keys of the time, topic and source of a log in maps and query results.
+const (
+	TimeKey   = "__time__"
+	TopicKey  = "__topic__"
+	SourceKey = "__source__"
+
+	// TimeNsPartKey is the key of the nanosecond part of the time of a
+	// log in query results, if it has one.
+	TimeNsPartKey = "__time_ns_part__"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Encoder turns Go values into logs. The zero value formats the values of
+// the contents like DefaultEncoder, its Format functions replace the
+// default formatting of some kinds of values.
+//
+// A struct is encoded field by field, each field being a content keyed by
+// the name in its sls tag or by the field name. The tag options are:
+//
+//	Name string `sls:"name"`           // content "name"
+//	Opt  string `sls:"opt,omitempty"`  // no content if Opt is a zero value
+//	At   int64  `sls:",time"`          // Log.Time, in seconds or a time.Time
+//	Skip string `sls:"-"`              // no content
+//
+// The fields tagged topic or source, see DecodeLogGroup and
+// GetLogsResponse.Decode, aren't encoded since the topic and source are the
+// ones of the log group. DecodeLog decodes the logs back into structs.
+//
+// The fields of a nested struct, or the entries of a nested map, are
+// flattened with dotted keys, e.g. "request.method", and the fields of an
+// embedded struct without a tag are encoded as fields of the outer struct.
+// Nil pointers and interfaces are omitted.
+//
+// A map with string keys is encoded entry by entry, sorted by key, and its
+// entry TimeKey is the Log.Time. Logs without a time are stamped with the
+// current time.
+type Encoder struct {
+	// FormatNumber formats the ints, uints and floats,
+	// nil means strconv formatting.
+	FormatNumber func(v interface{}) string
+	// FormatBool formats the bools, nil means "true" and "false".
+	FormatBool func(v bool) string
+	// FormatSlice formats the slices and arrays but []byte,
+	// nil means JSON.
+	FormatSlice func(v interface{}) (string, error)
+	// FormatText formats the values implementing encoding.TextMarshaler,
+	// e.g. time.Time, nil means MarshalText.
+	FormatText func(v encoding.TextMarshaler) (string, error)
+}
+
+// DefaultEncoder is the Encoder used by EncodeLog.
+var DefaultEncoder = &Encoder{}
+
+// EncodeLog encodes v, a struct or a map with string keys or a pointer to
+// one, into a log with DefaultEncoder.
+func EncodeLog(v interface{}) (*Log, error) {
+	return DefaultEncoder.Encode(v)
+}
+
+// Encode encodes v, a struct or a map with string keys or a pointer to one,
+// into a log.
+func (e *Encoder) Encode(v interface{}) (*Log, error) {
+	rv := indirect(reflect.ValueOf(v))
+	l := &Log{}
+	var err error
+	switch {
+	case rv.Kind() == reflect.Struct && !isText(rv):
+		err = e.encodeStruct(l, "", rv)
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		err = e.encodeMap(l, "", rv)
+	default:
+		err = fmt.Errorf("can't encode %T into a log", v)
+	}
+	if err != nil {
+		return nil, NewClientError(err.Error())
+	}
+	if l.Time == nil {
+		l.Time = proto.Uint32(uint32(time.Now().Unix()))
+	}
+	return l, nil
+}
+
+// indirect dereferences the pointers and interfaces of v, it returns the
+// zero Value if one is nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isText tells whether v implements encoding.TextMarshaler.
+func isText(v reflect.Value) bool {
+	return v.Type().Implements(textMarshalerType) ||
+		v.CanAddr() && v.Addr().Type().Implements(textMarshalerType)
+}
+
+// fieldTag is the sls tag of a struct field.
+type fieldTag struct {
+	key       string // Content key, empty for an embedded struct without a tag
+	omitEmpty bool
+	special   string // TimeKey, TopicKey or SourceKey for the tag options time, topic and source
+}
+
+// parseField returns the tag of struct field f, skip is true if f is
+// neither encoded nor decoded.
+func parseField(f reflect.StructField) (tag fieldTag, skip bool) {
+	s, ok := f.Tag.Lookup("sls")
+	if s == "-" || f.PkgPath != "" && !f.Anonymous {
+		return tag, true
+	}
+	parts := strings.Split(s, ",")
+	tag.key = parts[0]
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			tag.omitEmpty = true
+		case "time":
+			tag.special = TimeKey
+		case "topic":
+			tag.special = TopicKey
+		case "source":
+			tag.special = SourceKey
+		}
+	}
+	if tag.key == "" && !(f.Anonymous && !ok) {
+		tag.key = f.Name
+	}
+	return tag, false
+}
+
+func (e *Encoder) encodeStruct(l *Log, prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, skip := parseField(f)
+		if skip || tag.special == TopicKey || tag.special == SourceKey {
+			continue
+		}
+		fv := v.Field(i)
+		if tag.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if tag.special == TimeKey {
+			if err := setTime(l, fv); err != nil {
+				return fmt.Errorf("field %v: %v", f.Name, err)
+			}
+			continue
+		}
+		key := tag.key
+		if key == "" {
+			// An embedded struct without a tag, its fields are ours. Like
+			// encoding/json, the other unexported embedded types are skipped.
+			ev := indirect(fv)
+			if !ev.IsValid() || f.PkgPath != "" && (fv.Kind() != reflect.Struct || isText(fv)) {
+				continue
+			}
+			if ev.Kind() == reflect.Struct && !isText(ev) {
+				if err := e.encodeStruct(l, prefix, ev); err != nil {
+					return err
+				}
+				continue
+			}
+			key = f.Name
+		}
+		if err := e.encodeValue(l, prefix+key, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeMap(l *Log, prefix string, v reflect.Value) error {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+		values[k.String()] = v.MapIndex(k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if prefix == "" && k == TimeKey {
+			if err := setTime(l, values[k]); err != nil {
+				return fmt.Errorf("entry %v: %v", k, err)
+			}
+			continue
+		}
+		if err := e.encodeValue(l, prefix+k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeValue adds the contents of value v with key.
+func (e *Encoder) encodeValue(l *Log, key string, v reflect.Value) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	if isText(v) {
+		s, err := e.formatText(v)
+		if err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		}
+		addContent(l, key, s)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		addContent(l, key, v.String())
+	case reflect.Bool:
+		if e.FormatBool != nil {
+			addContent(l, key, e.FormatBool(v.Bool()))
+		} else {
+			addContent(l, key, strconv.FormatBool(v.Bool()))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		addContent(l, key, e.formatNumber(v))
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			addContent(l, key, string(v.Bytes()))
+			return nil
+		}
+		s, err := e.formatSlice(v)
+		if err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		}
+		addContent(l, key, s)
+	case reflect.Struct:
+		return e.encodeStruct(l, key+".", v)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%v: can't encode %v", key, v.Type())
+		}
+		return e.encodeMap(l, key+".", v)
+	default:
+		return fmt.Errorf("%v: can't encode %v", key, v.Type())
+	}
+	return nil
+}
+
+func (e *Encoder) formatNumber(v reflect.Value) string {
+	if e.FormatNumber != nil {
+		return e.FormatNumber(v.Interface())
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	}
+	return strconv.FormatUint(v.Uint(), 10)
+}
+
+func (e *Encoder) formatSlice(v reflect.Value) (string, error) {
+	if e.FormatSlice != nil {
+		return e.FormatSlice(v.Interface())
+	}
+	b, err := json.Marshal(v.Interface())
+	return string(b), err
+}
+
+func (e *Encoder) formatText(v reflect.Value) (string, error) {
+	var m encoding.TextMarshaler
+	if v.Type().Implements(textMarshalerType) {
+		m = v.Interface().(encoding.TextMarshaler)
+	} else {
+		m = v.Addr().Interface().(encoding.TextMarshaler)
+	}
+	if e.FormatText != nil {
+		return e.FormatText(m)
+	}
+	b, err := m.MarshalText()
+	return string(b), err
+}
+
+// setTime sets the time of log l to v, a time.Time with its nanosecond
+// part or a number of seconds.
+func setTime(l *Log, v reflect.Value) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	switch {
+	case v.Type() == timeType:
+		l.SetTimestamp(v.Interface().(time.Time))
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		l.Time = proto.Uint32(uint32(v.Int()))
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr:
+		l.Time = proto.Uint32(uint32(v.Uint()))
+	default:
+		return fmt.Errorf("can't use %v as a time", v.Type())
+	}
+	return nil
+}
+
+func addContent(l *Log, key, value string) {
+	l.Contents = append(l.Contents, &LogContent{Key: proto.String(key), Value: proto.String(value)})
+}
@@ -0,0 +1,157 @@
+package sls
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contents returns the contents of log l as "key=value" lines.
+func contents(l *Log) string {
+	var lines []string
+	for _, c := range l.Contents {
+		lines = append(lines, c.GetKey()+"="+c.GetValue())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type encodeRequest struct {
+	Method  string            `sls:"method"`
+	Path    string            `sls:"path,omitempty"`
+	Headers map[string]string `sls:"headers"`
+}
+
+type encodeMeta struct {
+	Host string `sls:"host"`
+}
+
+type encodeEvent struct {
+	encodeMeta
+	At       time.Time      `sls:",time"`
+	Level    string         `sls:"level"`
+	Code     int            `sls:"code"`
+	Latency  float64        `sls:"latency"`
+	OK       bool           `sls:"ok"`
+	Tags     []string       `sls:"tags"`
+	Body     []byte         `sls:"body"`
+	IP       net.IP         `sls:"ip"`
+	Request  *encodeRequest `sls:"request"`
+	Missing  *encodeRequest `sls:"missing"`
+	Note     string         `sls:"note,omitempty"`
+	Internal string         `sls:"-"`
+	Untagged uint8
+	private  string
+}
+
+func TestEncodeStruct(t *testing.T) {
+	at := time.Unix(1500000000, 0)
+	ev := &encodeEvent{
+		encodeMeta: encodeMeta{Host: "host-1"},
+		At:         at,
+		Level:      "info",
+		Code:       200,
+		Latency:    0.25,
+		OK:         true,
+		Tags:       []string{"a", "b"},
+		Body:       []byte("body"),
+		IP:         net.IPv4(10, 0, 0, 1),
+		Request: &encodeRequest{
+			Method:  "GET",
+			Headers: map[string]string{"b": "2", "a": "1"},
+		},
+		Internal: "internal",
+		Untagged: 7,
+		private:  "private",
+	}
+	l, err := EncodeLog(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.GetTime() != 1500000000 {
+		t.Errorf("unexpected time %v", l.GetTime())
+	}
+	expected := strings.Join([]string{
+		"host=host-1",
+		"level=info",
+		"code=200",
+		"latency=0.25",
+		"ok=true",
+		`tags=["a","b"]`,
+		"body=body",
+		"ip=10.0.0.1",
+		"request.method=GET",
+		"request.headers.a=1",
+		"request.headers.b=2",
+		"Untagged=7",
+	}, "\n")
+	if c := contents(l); c != expected {
+		t.Errorf("unexpected contents:\n%v", c)
+	}
+}
+
+func TestEncodeMap(t *testing.T) {
+	l, err := EncodeLog(map[string]interface{}{
+		TimeKey: int64(1500000000),
+		"b":     2,
+		"a":     "1",
+		"c":     map[string]interface{}{"d": nil, "e": time.Unix(0, 0).UTC()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.GetTime() != 1500000000 {
+		t.Errorf("unexpected time %v", l.GetTime())
+	}
+	expected := "a=1\nb=2\nc.e=1970-01-01T00:00:00Z"
+	if c := contents(l); c != expected {
+		t.Errorf("unexpected contents:\n%v", c)
+	}
+
+	before := uint32(time.Now().Unix())
+	l, err = EncodeLog(map[string]string{"a": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.GetTime() < before {
+		t.Errorf("unexpected time %v", l.GetTime())
+	}
+}
+
+func TestEncoderFormat(t *testing.T) {
+	e := &Encoder{
+		FormatNumber: func(v interface{}) string { return fmt.Sprintf("%.2f", v) },
+		FormatBool: func(v bool) string {
+			if v {
+				return "1"
+			}
+			return "0"
+		},
+		FormatSlice: func(v interface{}) (string, error) { return fmt.Sprintf("%v", v), nil },
+	}
+	l, err := e.Encode(map[string]interface{}{"f": 1.5, "b": false, "s": []int{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := contents(l); c != "b=0\nf=1.50\ns=[1 2]" {
+		t.Errorf("unexpected contents:\n%v", c)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, v := range []interface{}{
+		"not a struct",
+		map[int]string{1: "1"},
+		map[string]interface{}{"f": func() {}},
+		struct {
+			At string `sls:",time"`
+		}{"now"},
+	} {
+		if _, err := EncodeLog(v); err == nil {
+			t.Errorf("no error encoding %#v", v)
+		} else if slsErr, ok := err.(*Error); !ok || slsErr.Code != "ClientError" {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package sls
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Default values of EndpointPool.
+const (
+	DefaultEndpointFailureThreshold = 3
+	DefaultEndpointOpenTimeout      = 30 * time.Second
+)
+
+// EndpointPool is a list of endpoints of a region in order of preference,
+// e.g. the intranet endpoint then the public one, with a circuit breaker
+// for each endpoint.
+//
+// The requests are sent to the first endpoint whose breaker is closed. An
+// attempt failing because of its endpoint, i.e. with a network error or a
+// server error (5xx), is retried on the next endpoint, if the RetryPolicy
+// retries it. FailureThreshold such failures in a row open the breaker of
+// the endpoint: it's skipped for OpenTimeout, then probed by the next
+// request, whose success closes the breaker again.
+//
+// An EndpointPool can be shared by several projects.
+type EndpointPool struct {
+	Endpoints        []string      // Endpoints in order of preference
+	FailureThreshold int           // Failures in a row opening a breaker, 0 means DefaultEndpointFailureThreshold
+	OpenTimeout      time.Duration // Time an open breaker skips its endpoint, 0 means DefaultEndpointOpenTimeout
+
+	mu     sync.Mutex
+	states map[string]*endpointState
+}
+
+// endpointState is the circuit breaker of an endpoint.
+type endpointState struct {
+	failures  int       // Endpoint failures in a row
+	openUntil time.Time // Zero if the breaker is closed
+}
+
+// NewEndpointPool creates a pool of endpoints in order of preference.
+func NewEndpointPool(endpoints ...string) *EndpointPool {
+	return &EndpointPool{Endpoints: endpoints}
+}
+
+// RegionEndpoints returns the endpoints of region in order of preference:
+// the intranet endpoint, reachable from the ECS instances of the region,
+// then the public endpoint.
+func RegionEndpoints(region string) []string {
+	return []string{
+		region + "-intranet.log.aliyuncs.com",
+		region + ".log.aliyuncs.com",
+	}
+}
+
+// state returns the breaker of endpoint, p.mu must be held.
+func (p *EndpointPool) state(endpoint string) *endpointState {
+	if p.states == nil {
+		p.states = make(map[string]*endpointState)
+	}
+	s, ok := p.states[endpoint]
+	if !ok {
+		s = &endpointState{}
+		p.states[endpoint] = s
+	}
+	return s
+}
+
+func (s *endpointState) available(now time.Time) bool {
+	return s.openUntil.IsZero() || !now.Before(s.openUntil)
+}
+
+// Available returns the endpoints whose breaker doesn't skip them, in order
+// of preference.
+func (p *EndpointPool) Available() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var endpoints []string
+	for _, endpoint := range p.Endpoints {
+		if p.state(endpoint).available(now) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// pick returns the endpoint of the next attempt of a request. failed is
+// the endpoint of the previous attempt if it failed because of it, the
+// attempt goes to another endpoint if any. If all the breakers are open,
+// the endpoint reopening first is probed.
+func (p *EndpointPool) pick(failed string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var next string
+	var nextOpenUntil time.Time
+	for _, endpoint := range p.Endpoints {
+		if endpoint == failed {
+			continue
+		}
+		s := p.state(endpoint)
+		if s.available(now) {
+			return endpoint
+		}
+		if next == "" || s.openUntil.Before(nextOpenUntil) {
+			next, nextOpenUntil = endpoint, s.openUntil
+		}
+	}
+	if failed != "" && (next == "" || p.state(failed).available(now)) {
+		return failed
+	}
+	return next
+}
+
+// report updates the breaker of endpoint with the result of an attempt.
+func (p *EndpointPool) report(endpoint string, err error) {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.state(endpoint)
+	if !isEndpointFailure(err) {
+		s.failures = 0
+		s.openUntil = time.Time{}
+		return
+	}
+
+	s.failures++
+	threshold := p.FailureThreshold
+	if threshold <= 0 {
+		threshold = DefaultEndpointFailureThreshold
+	}
+	// A failed probe opens the breaker again at once.
+	if s.failures >= threshold || !s.openUntil.IsZero() {
+		timeout := p.OpenTimeout
+		if timeout <= 0 {
+			timeout = DefaultEndpointOpenTimeout
+		}
+		s.openUntil = time.Now().Add(timeout)
+	}
+}
+
+// isEndpointFailure tells whether an attempt failed with err because of
+// its endpoint, so that another endpoint may succeed.
+func isEndpointFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if e := slsError(err); e != nil {
+		return e.HTTPCode >= http.StatusInternalServerError
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,114 @@
+package sls
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// downTransport fails the requests to the hosts ending with down, and
+// answers the other ones with empty 200 responses.
+type downTransport struct {
+	down  string
+	hosts []string
+}
+
+func (t *downTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.hosts = append(t.hosts, req.Host)
+	if strings.HasSuffix(req.Host, t.down) {
+		return nil, errors.New("connection refused")
+	}
+	return (&scriptTransport{}).RoundTrip(req)
+}
+
+func TestEndpointFailover(t *testing.T) {
+	rt := &downTransport{down: "cn-hangzhou-intranet.log.aliyuncs.com"}
+	p := newRetryTestProject(rt)
+	p.WithEndpoints(RegionEndpoints("cn-hangzhou")...)
+	p.EndpointPool.FailureThreshold = 2
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.ListLogStore(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"test-retry.cn-hangzhou-intranet.log.aliyuncs.com",
+		"test-retry.cn-hangzhou.log.aliyuncs.com",
+		"test-retry.cn-hangzhou-intranet.log.aliyuncs.com",
+		"test-retry.cn-hangzhou.log.aliyuncs.com",
+		// The breaker of the intranet endpoint is open.
+		"test-retry.cn-hangzhou.log.aliyuncs.com",
+	}
+	if strings.Join(rt.hosts, " ") != strings.Join(expected, " ") {
+		t.Errorf("unexpected hosts: %v", rt.hosts)
+	}
+	if available := p.EndpointPool.Available(); len(available) != 1 || available[0] != "cn-hangzhou.log.aliyuncs.com" {
+		t.Errorf("unexpected available endpoints: %v", available)
+	}
+}
+
+func TestEndpointNoFailoverOnClientError(t *testing.T) {
+	rt := &scriptTransport{script: []*Error{
+		{Code: "ParameterInvalid", HTTPCode: http.StatusBadRequest},
+	}}
+	p := newRetryTestProject(rt)
+	p.WithEndpoints("a.log.aliyuncs.com", "b.log.aliyuncs.com")
+
+	_, err := p.ListLogStore()
+	var e *Error
+	if !errors.As(err, &e) || e.Endpoint != "a.log.aliyuncs.com" {
+		t.Fatalf("expected an error of the first endpoint, got %#v", err)
+	}
+	if len(rt.reqs) != 1 {
+		t.Errorf("expected 1 attempt, got %v", len(rt.reqs))
+	}
+}
+
+func TestEndpointPoolBreaker(t *testing.T) {
+	pool := &EndpointPool{
+		Endpoints:        []string{"a", "b"},
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+	}
+	serverErr := &Error{Code: "InternalServerError", HTTPCode: http.StatusInternalServerError}
+
+	if e := pool.pick(""); e != "a" {
+		t.Fatalf("expected a, got %v", e)
+	}
+	if e := pool.pick("a"); e != "b" {
+		t.Errorf("expected to fail over to b, got %v", e)
+	}
+	pool.report("a", serverErr)
+	pool.report("a", &Error{Code: "WriteQuotaExceed", HTTPCode: http.StatusForbidden})
+	pool.report("a", serverErr)
+	if e := pool.pick(""); e != "a" {
+		t.Errorf("expected a, a quota error isn't a failure of the endpoint, got %v", e)
+	}
+	pool.report("a", serverErr)
+	if e := pool.pick(""); e != "b" {
+		t.Errorf("expected b while the breaker of a is open, got %v", e)
+	}
+
+	// Both breakers are open, the first one to reopen is probed.
+	pool.report("b", serverErr)
+	pool.report("b", serverErr)
+	if e := pool.pick(""); e != "a" {
+		t.Errorf("expected to probe a, got %v", e)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if available := pool.Available(); len(available) != 2 {
+		t.Errorf("expected both endpoints to be probed, got %v", available)
+	}
+	pool.report("a", serverErr)
+	if available := pool.Available(); len(available) != 1 || available[0] != "b" {
+		t.Errorf("expected a failed probe to open the breaker again, got %v", available)
+	}
+	pool.report("b", nil)
+	if e := pool.pick(""); e != "b" {
+		t.Errorf("expected b after a successful probe, got %v", e)
+	}
+}
@@ -0,0 +1,59 @@
+package sls
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// authFailureCodes are the SLS error codes of the requests rejected
+// because of their credentials or signature.
+var authFailureCodes = map[string]bool{
+	"Unauthorized":         true,
+	"SignatureNotMatch":    true,
+	"InvalidAccessKeyId":   true,
+	"SecurityTokenExpired": true,
+	"InvalidSecurityToken": true,
+	"RequestTimeExpired":   true,
+}
+
+// slsError returns the *Error in the chain of err, or nil.
+func slsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
+
+// IsNotExist tells whether err reports that a resource, e.g. a project,
+// logstore, config or consumer group, doesn't exist.
+func IsNotExist(err error) bool {
+	e := slsError(err)
+	return e != nil && (strings.HasSuffix(e.Code, "NotExist") || e.HTTPCode == http.StatusNotFound)
+}
+
+// IsQuotaExceeded tells whether err reports that a read or write quota of
+// a project or shard is exceeded.
+func IsQuotaExceeded(err error) bool {
+	e := slsError(err)
+	return e != nil && (strings.Contains(e.Code, "QuotaExceed") || e.Code == "ExceedQuota")
+}
+
+// IsAuthFailure tells whether err reports that a request was rejected
+// because of its credentials, signature or permissions.
+func IsAuthFailure(err error) bool {
+	e := slsError(err)
+	if e == nil {
+		return false
+	}
+	return authFailureCodes[e.Code] || e.HTTPCode == http.StatusUnauthorized ||
+		e.HTTPCode == http.StatusForbidden && !IsQuotaExceeded(err)
+}
+
+// IsRetryable tells whether the request failed with err can be sent again,
+// assuming it's idempotent, like DefaultRetryPolicy decides it.
+func IsRetryable(err error) bool {
+	var p BackoffPolicy
+	return p.retryable(true, err)
+}
@@ -0,0 +1,112 @@
+package sls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// failingTransport fails every request with err.
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+func TestErrorOfFailedRequest(t *testing.T) {
+	rt := &scriptTransport{script: []*Error{
+		{Code: "LogStoreNotExist", Message: "logstore test-errors does not exist", HTTPCode: http.StatusNotFound},
+	}}
+	p := newRetryTestProject(rt)
+	_, err := p.GetLogStore("test-errors")
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected an *Error, got %#v", err)
+	}
+	if e.Operation != "GetLogStore" || e.Endpoint != p.Endpoint || e.HTTPCode != http.StatusNotFound ||
+		e.Code != "LogStoreNotExist" {
+		t.Errorf("unexpected error: %#v", e)
+	}
+	if !errors.Is(err, &Error{Code: "LogStoreNotExist"}) || errors.Is(err, &Error{Code: "ProjectNotExist"}) {
+		t.Errorf("errors.Is doesn't match the code of %v", err)
+	}
+	if !IsNotExist(err) || IsRetryable(err) || IsAuthFailure(err) || IsQuotaExceeded(err) {
+		t.Errorf("unexpected predicates of %v", err)
+	}
+}
+
+func TestErrorOfUndecodableResponse(t *testing.T) {
+	// The responses are "{}", which isn't a list of shards.
+	p := newRetryTestProject(&scriptTransport{})
+	s := &LogStore{Name: "test-errors", project: p}
+	_, err := s.ListShardInfos()
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected an *Error, got %#v", err)
+	}
+	if e.Operation != "ListShardInfos" || e.Endpoint != p.Endpoint || e.Code != "ClientError" {
+		t.Errorf("unexpected error: %#v", e)
+	}
+}
+
+func TestErrorWrapsNetworkError(t *testing.T) {
+	cause := errors.New("connection reset by peer")
+	p := newRetryTestProject(failingTransport{cause})
+	err := p.DeleteLogStore("test-errors")
+
+	var e *Error
+	if !errors.As(err, &e) || e.Code != "ClientError" || e.Operation != "DeleteLogStore" {
+		t.Fatalf("expected a ClientError of DeleteLogStore, got %#v", err)
+	}
+	var urlErr *url.Error
+	if !errors.Is(err, cause) || !errors.As(err, &urlErr) {
+		t.Errorf("%v doesn't wrap its cause", err)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("network error %v isn't retryable", err)
+	}
+}
+
+func TestErrorPredicates(t *testing.T) {
+	cases := []struct {
+		err                                     error
+		notExist, quota, retryable, authFailure bool
+	}{
+		{err: &Error{Code: "ProjectNotExist", HTTPCode: 404}, notExist: true},
+		{err: &Error{Code: "ConsumerGroupNotExist", HTTPCode: 404}, notExist: true},
+		{err: &Error{Code: "WriteQuotaExceed", HTTPCode: 403}, quota: true, retryable: true},
+		{err: &Error{Code: "ShardReadQuotaExceed", HTTPCode: 403}, quota: true, retryable: true},
+		{err: &Error{Code: "ExceedQuota", HTTPCode: 400}, quota: true},
+		{err: &Error{Code: "ServerBusy", HTTPCode: 503}, retryable: true},
+		{err: &Error{Code: "InternalServerError", HTTPCode: 500}, retryable: true},
+		{err: &Error{Code: "SignatureNotMatch", HTTPCode: 401}, authFailure: true},
+		{err: &Error{Code: "SecurityTokenExpired", HTTPCode: 401}, authFailure: true},
+		{err: &Error{Code: "Unauthorized", HTTPCode: 403}, authFailure: true},
+		{err: &Error{Code: "ParameterInvalid", HTTPCode: 400}},
+		{err: fmt.Errorf("put logs: %w", &Error{Code: "LogStoreNotExist", HTTPCode: 404}), notExist: true},
+		{err: context.DeadlineExceeded},
+		{err: errors.New("not an sls error")},
+		{err: nil},
+	}
+	for _, c := range cases {
+		if got := IsNotExist(c.err); got != c.notExist {
+			t.Errorf("IsNotExist(%v) = %v", c.err, got)
+		}
+		if got := IsQuotaExceeded(c.err); got != c.quota {
+			t.Errorf("IsQuotaExceeded(%v) = %v", c.err, got)
+		}
+		if got := IsRetryable(c.err); got != c.retryable {
+			t.Errorf("IsRetryable(%v) = %v", c.err, got)
+		}
+		if got := IsAuthFailure(c.err); got != c.authFailure {
+			t.Errorf("IsAuthFailure(%v) = %v", c.err, got)
+		}
+	}
+}
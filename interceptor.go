@@ -0,0 +1,41 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+)
+
+// Request is an attempt of a request to SLS, as seen by the interceptors.
+type Request struct {
+	Project   string            // Project name
+	Operation string            // Name of the SDK method, e.g. "PutLogs"
+	Method    string            // HTTP method
+	URI       string            // Path and query, e.g. "/logstores/app_log/shards/0?type=cursor&from=end"
+	Headers   map[string]string // Headers to sign and send, shared by the attempts of the request
+	Body      []byte            // Body to send, possibly compressed
+
+	Attempt  int    // Number of the attempt, starting at 1
+	Endpoint string // Endpoint of the attempt, see EndpointPool
+}
+
+// Handler sends a request to SLS. On failure, the error is an *Error for
+// the requests rejected by SLS.
+type Handler func(ctx context.Context, req *Request) (*http.Response, error)
+
+// Interceptor intercepts the attempts of the requests of a project, before
+// they're signed and sent. It can mutate req, e.g. add a header, then call
+// next to send it and observe or replace its result, or return without
+// calling next to short-circuit the request, e.g. to inject faults. The
+// errors it returns are retried like the errors of SLS.
+type Interceptor func(ctx context.Context, req *Request, next Handler) (*http.Response, error)
+
+// chainInterceptors returns a handler calling the interceptors in order, then h.
+func chainInterceptors(interceptors []Interceptor, h Handler) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], h
+		h = func(ctx context.Context, req *Request) (*http.Response, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return h
+}
@@ -0,0 +1,109 @@
+package sls
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestInterceptorsOrder(t *testing.T) {
+	rt := &recordTransport{}
+	p, _ := NewLogProject("test-interceptor", "cn-hangzhou.log.aliyuncs.com",
+		"mockAccessKeyID", "mockAccessKeySecret")
+	p.WithHTTPClient(&http.Client{Transport: rt})
+
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, req *Request, next Handler) (*http.Response, error) {
+			calls = append(calls, name+" "+req.Method+" "+req.URI)
+			req.Headers["x-log-"+name] = "true"
+			resp, err := next(ctx, req)
+			calls = append(calls, name+" done")
+			return resp, err
+		}
+	}
+	p.WithInterceptors(record("first"), record("second"))
+
+	if _, err := p.ListLogStore(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"first GET /logstores", "second GET /logstores", "second done", "first done"}
+	if len(calls) != len(expected) {
+		t.Fatalf("bad calls:%v", calls)
+	}
+	for i := range calls {
+		if calls[i] != expected[i] {
+			t.Errorf("bad call %v:%v, expected:%v", i, calls[i], expected[i])
+		}
+	}
+
+	// The headers added by the interceptors are sent and signed.
+	req := rt.reqs[0]
+	if req.Header.Get("x-log-first") != "true" || req.Header.Get("x-log-second") != "true" {
+		t.Errorf("missing interceptor headers:%v", req.Header)
+	}
+	h := map[string]string{}
+	for k := range req.Header {
+		h[k] = req.Header.Get(k)
+	}
+	digest, _ := signature("mockAccessKeySecret", "GET", "/logstores", h)
+	if req.Header.Get("Authorization") != "SLS mockAccessKeyID:"+digest {
+		t.Errorf("interceptor headers aren't signed")
+	}
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	rt := &recordTransport{}
+	p, _ := NewLogProject("test-interceptor", "cn-hangzhou.log.aliyuncs.com",
+		"mockAccessKeyID", "mockAccessKeySecret")
+	p.WithHTTPClient(&http.Client{Transport: rt})
+	p.WithRetryPolicy(&BackoffPolicy{MaxAttempts: 3})
+
+	// Fail the first 2 attempts with a retryable error.
+	var attempts []int
+	p.WithInterceptors(func(ctx context.Context, req *Request, next Handler) (*http.Response, error) {
+		attempts = append(attempts, req.Attempt)
+		if req.Attempt < 3 {
+			return nil, &Error{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable}
+		}
+		return next(ctx, req)
+	})
+	if _, err := p.ListLogStore(); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 3 || attempts[2] != 3 {
+		t.Errorf("bad attempts:%v", attempts)
+	}
+	if len(rt.reqs) != 1 {
+		t.Errorf("expected 1 request sent, got %v", len(rt.reqs))
+	}
+
+	errInjected := errors.New("injected")
+	p.WithInterceptors(func(ctx context.Context, req *Request, next Handler) (*http.Response, error) {
+		return nil, errInjected
+	})
+	if _, err := p.CheckLogstoreExist("test-logstore"); err == nil {
+		t.Errorf("expected the injected error")
+	}
+	if len(rt.reqs) != 1 {
+		t.Errorf("expected no more request sent, got %v", len(rt.reqs))
+	}
+}
+
+func TestInterceptorObservesError(t *testing.T) {
+	p := newRetryTestProject(&scriptTransport{script: []*Error{
+		{Code: "LogStoreNotExist", HTTPCode: http.StatusNotFound},
+	}})
+
+	var observed *Error
+	p.WithInterceptors(func(ctx context.Context, req *Request, next Handler) (*http.Response, error) {
+		resp, err := next(ctx, req)
+		errors.As(err, &observed)
+		return resp, err
+	})
+	p.GetLogStore("test-logstore")
+	if observed == nil || observed.Code != "LogStoreNotExist" || observed.HTTPCode != http.StatusNotFound {
+		t.Errorf("bad observed error:%v", observed)
+	}
+}
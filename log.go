@@ -0,0 +1,74 @@
+package sls
+
+import "time"
+
+// Keys of the tags set on the log groups by Logtail and by the service.
+const (
+	TagHostname    = "__hostname__"
+	TagPath        = "__path__"
+	TagPackID      = "__pack_id__"
+	TagClientIP    = "__client_ip__"
+	TagReceiveTime = "__receive_time__"
+)
+
+// TagKeyPrefix prefixes the keys of the tags in query results, e.g.
+// "__tag__:__hostname__".
+const TagKeyPrefix = "__tag__:"
+
+// Timestamp returns the time of log l, with its nanosecond part.
+func (l *Log) Timestamp() time.Time {
+	return time.Unix(int64(l.GetTime()), int64(l.GetTimeNs()))
+}
+
+// SetTimestamp sets the time of log l to t. The nanosecond part is only
+// set if not 0, so the logs stay the same for the servers ignoring it.
+func (l *Log) SetTimestamp(t time.Time) {
+	sec := uint32(t.Unix())
+	l.Time = &sec
+	l.TimeNs = nil
+	if ns := uint32(t.Nanosecond()); ns != 0 {
+		l.TimeNs = &ns
+	}
+}
+
+// Tag returns the value of the tag of log group lg with key.
+func (lg *LogGroup) Tag(key string) (value string, ok bool) {
+	for _, t := range lg.LogTags {
+		if t.GetKey() == key {
+			return t.GetValue(), true
+		}
+	}
+	return "", false
+}
+
+// SetTag sets the tag of log group lg with key to value.
+func (lg *LogGroup) SetTag(key, value string) {
+	for _, t := range lg.LogTags {
+		if t.GetKey() == key {
+			t.Value = &value
+			return
+		}
+	}
+	lg.LogTags = append(lg.LogTags, &LogTag{Key: &key, Value: &value})
+}
+
+// DeleteTag deletes the tag of log group lg with key. The tags are copied,
+// so the log groups sharing them aren't changed.
+func (lg *LogGroup) DeleteTag(key string) {
+	var tags []*LogTag
+	for _, t := range lg.LogTags {
+		if t.GetKey() != key {
+			tags = append(tags, t)
+		}
+	}
+	lg.LogTags = tags
+}
+
+// Tags returns the tags of log group lg by key.
+func (lg *LogGroup) Tags() map[string]string {
+	tags := make(map[string]string, len(lg.LogTags))
+	for _, t := range lg.LogTags {
+		tags[t.GetKey()] = t.GetValue()
+	}
+	return tags
+}
@@ -27,6 +27,7 @@ var _ = math.Inf
 type Log struct {
 	Time            *uint32       `protobuf:"varint,1,req,name=Time" json:"Time,omitempty"`
 	Contents        []*LogContent `protobuf:"bytes,2,rep,name=Contents" json:"Contents,omitempty"`
+	TimeNs          *uint32       `protobuf:"fixed32,4,opt,name=Time_ns" json:"Time_ns,omitempty"`
 	XXXUnrecognized []byte        `json:"-"`
 }
 
@@ -53,6 +54,14 @@ func (m *Log) GetContents() []*LogContent {
 	return nil
 }
 
+// GetTimeNs get the nanosecond part of log timestamp
+func (m *Log) GetTimeNs() uint32 {
+	if m != nil && m.TimeNs != nil {
+		return *m.TimeNs
+	}
+	return 0
+}
+
 // LogContent defines log content in SLS
 type LogContent struct {
 	Key             *string `protobuf:"bytes,1,req,name=Key" json:"Key,omitempty"`
@@ -83,13 +92,44 @@ func (m *LogContent) GetValue() string {
 	return ""
 }
 
+// LogTag defines log tag in SLS
+type LogTag struct {
+	Key             *string `protobuf:"bytes,1,req,name=Key" json:"Key,omitempty"`
+	Value           *string `protobuf:"bytes,2,req,name=Value" json:"Value,omitempty"`
+	XXXUnrecognized []byte  `json:"-"`
+}
+
+// Reset set empty log tag
+func (m *LogTag) Reset()         { *m = LogTag{} }
+func (m *LogTag) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage empty implement
+func (*LogTag) ProtoMessage() {}
+
+// GetKey get log tag's key
+func (m *LogTag) GetKey() string {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return ""
+}
+
+// GetValue get log tag's value
+func (m *LogTag) GetValue() string {
+	if m != nil && m.Value != nil {
+		return *m.Value
+	}
+	return ""
+}
+
 // LogGroup defines log group in SLS
 type LogGroup struct {
-	Logs            []*Log  `protobuf:"bytes,1,rep,name=Logs" json:"Logs,omitempty"`
-	Reserved        *string `protobuf:"bytes,2,opt,name=Reserved" json:"Reserved,omitempty"`
-	Topic           *string `protobuf:"bytes,3,opt,name=Topic" json:"Topic,omitempty"`
-	Source          *string `protobuf:"bytes,4,opt,name=Source" json:"Source,omitempty"`
-	XXXUnrecognized []byte  `json:"-"`
+	Logs            []*Log    `protobuf:"bytes,1,rep,name=Logs" json:"Logs,omitempty"`
+	Reserved        *string   `protobuf:"bytes,2,opt,name=Reserved" json:"Reserved,omitempty"`
+	Topic           *string   `protobuf:"bytes,3,opt,name=Topic" json:"Topic,omitempty"`
+	Source          *string   `protobuf:"bytes,4,opt,name=Source" json:"Source,omitempty"`
+	LogTags         []*LogTag `protobuf:"bytes,6,rep,name=LogTags" json:"LogTags,omitempty"`
+	XXXUnrecognized []byte    `json:"-"`
 }
 
 // Reset set empty log group
@@ -131,6 +171,14 @@ func (m *LogGroup) GetSource() string {
 	return ""
 }
 
+// GetLogTags get log group's tags
+func (m *LogGroup) GetLogTags() []*LogTag {
+	if m != nil {
+		return m.LogTags
+	}
+	return nil
+}
+
 // LogGroupList defines log group list
 type LogGroupList struct {
 	LogGroups       []*LogGroup `protobuf:"bytes,1,rep,name=logGroups" json:"logGroups,omitempty"`
@@ -187,6 +235,11 @@ func (m *Log) MarshalTo(data []byte) (int, error) {
 			i += n
 		}
 	}
+	if m.TimeNs != nil {
+		data[i] = 0x25
+		i++
+		i = encodeFixed32Log(data, i, uint32(*m.TimeNs))
+	}
 	if m.XXXUnrecognized != nil {
 		i += copy(data[i:], m.XXXUnrecognized)
 	}
@@ -234,6 +287,47 @@ func (m *LogContent) MarshalTo(data []byte) (int, error) {
 	return i, nil
 }
 
+// Marshal LogTag
+func (m *LogTag) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// MarshalTo return a int with Marshal
+func (m *LogTag) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Key == nil {
+		return 0, github_com_gogo_protobuf_proto.NewRequiredNotSetError("Key")
+	}
+
+	data[i] = 0xa
+	i++
+	i = encodeVarintLog(data, i, uint64(len(*m.Key)))
+	i += copy(data[i:], *m.Key)
+
+	if m.Value == nil {
+		return 0, github_com_gogo_protobuf_proto.NewRequiredNotSetError("Value")
+	}
+
+	data[i] = 0x12
+	i++
+	i = encodeVarintLog(data, i, uint64(len(*m.Value)))
+	i += copy(data[i:], *m.Value)
+
+	if m.XXXUnrecognized != nil {
+		i += copy(data[i:], m.XXXUnrecognized)
+	}
+	return i, nil
+}
+
 // Marshal LogGroup
 func (m *LogGroup) Marshal() (data []byte, err error) {
 	size := m.Size()
@@ -281,6 +375,18 @@ func (m *LogGroup) MarshalTo(data []byte) (int, error) {
 		i = encodeVarintLog(data, i, uint64(len(*m.Source)))
 		i += copy(data[i:], *m.Source)
 	}
+	if len(m.LogTags) > 0 {
+		for _, msg := range m.LogTags {
+			data[i] = 0x32
+			i++
+			i = encodeVarintLog(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
 	if m.XXXUnrecognized != nil {
 		i += copy(data[i:], m.XXXUnrecognized)
 	}
@@ -363,6 +469,9 @@ func (m *Log) Size() (n int) {
 			n += 1 + l + sovLog(uint64(l))
 		}
 	}
+	if m.TimeNs != nil {
+		n += 5
+	}
 	if m.XXXUnrecognized != nil {
 		n += len(m.XXXUnrecognized)
 	}
@@ -387,6 +496,24 @@ func (m *LogContent) Size() (n int) {
 	return n
 }
 
+// Size get LogTag size
+func (m *LogTag) Size() (n int) {
+	var l int
+	_ = l
+	if m.Key != nil {
+		l = len(*m.Key)
+		n += 1 + l + sovLog(uint64(l))
+	}
+	if m.Value != nil {
+		l = len(*m.Value)
+		n += 1 + l + sovLog(uint64(l))
+	}
+	if m.XXXUnrecognized != nil {
+		n += len(m.XXXUnrecognized)
+	}
+	return n
+}
+
 // Size get LogGroup size
 func (m *LogGroup) Size() (n int) {
 	var l int
@@ -409,6 +536,12 @@ func (m *LogGroup) Size() (n int) {
 		l = len(*m.Source)
 		n += 1 + l + sovLog(uint64(l))
 	}
+	if len(m.LogTags) > 0 {
+		for _, e := range m.LogTags {
+			l = e.Size()
+			n += 1 + l + sovLog(uint64(l))
+		}
+	}
 	if m.XXXUnrecognized != nil {
 		n += len(m.XXXUnrecognized)
 	}
@@ -528,6 +661,20 @@ func (m *Log) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 5 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TimeNs", wireType)
+			}
+			var v uint32
+			if (iNdEx + 4) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += 4
+			v = uint32(data[iNdEx-4])
+			v |= uint32(data[iNdEx-3]) << 8
+			v |= uint32(data[iNdEx-2]) << 16
+			v |= uint32(data[iNdEx-1]) << 24
+			m.TimeNs = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLog(data[iNdEx:])
@@ -676,6 +823,128 @@ func (m *LogContent) Unmarshal(data []byte) error {
 	return nil
 }
 
+// Unmarshal LogTag
+func (m *LogTag) Unmarshal(data []byte) error {
+	var hasFields [1]uint64
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLog
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LogTag: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LogTag: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLog
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLog
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(data[iNdEx:postIndex])
+			m.Key = &s
+			iNdEx = postIndex
+			hasFields[0] |= uint64(0x00000001)
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLog
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLog
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(data[iNdEx:postIndex])
+			m.Value = &s
+			iNdEx = postIndex
+			hasFields[0] |= uint64(0x00000002)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLog(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLog
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXXUnrecognized = append(m.XXXUnrecognized, data[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+	if hasFields[0]&uint64(0x00000001) == 0 {
+		return github_com_gogo_protobuf_proto.NewRequiredNotSetError("Key")
+	}
+	if hasFields[0]&uint64(0x00000002) == 0 {
+		return github_com_gogo_protobuf_proto.NewRequiredNotSetError("Value")
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
 // Unmarshal LogGroup
 func (m *LogGroup) Unmarshal(data []byte) error {
 	l := len(data)
@@ -827,6 +1096,37 @@ func (m *LogGroup) Unmarshal(data []byte) error {
 			s := string(data[iNdEx:postIndex])
 			m.Source = &s
 			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LogTags", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLog
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLog
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.LogTags = append(m.LogTags, &LogTag{})
+			if err := m.LogTags[len(m.LogTags)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLog(data[iNdEx:])
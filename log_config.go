@@ -1,5 +1,7 @@
 package sls
 
+import "context"
+
 // InputDetail defines log_config input
 type InputDetail struct {
 	LogType       string   `json:"logType"`
@@ -38,6 +40,11 @@ type LogConfig struct {
 
 // GetAppliedMachineGroup returns applied machine group of this config.
 func (c *LogConfig) GetAppliedMachineGroup(confName string) (groupNames []string, err error) {
-	groupNames, err = c.project.GetAppliedMachineGroups(c.Name)
+	return c.GetAppliedMachineGroupWithContext(context.Background(), confName)
+}
+
+// GetAppliedMachineGroupWithContext is like GetAppliedMachineGroup but uses ctx to cancel the request.
+func (c *LogConfig) GetAppliedMachineGroupWithContext(ctx context.Context, confName string) (groupNames []string, err error) {
+	groupNames, err = c.project.GetAppliedMachineGroupsWithContext(ctx, c.Name)
 	return
 }
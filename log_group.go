@@ -0,0 +1,92 @@
+package sls
+
+import "fmt"
+
+// Limits of a log group put by PutLogs, larger log groups are rejected.
+const (
+	MaxLogGroupSize  = 5 << 20 // Max size in bytes of a marshaled log group
+	MaxLogGroupCount = 4096    // Max number of logs in a log group
+)
+
+// checkLogGroup returns a ClientError if log group lg of size bytes is
+// over the limits of PutLogs.
+func checkLogGroup(lg *LogGroup, size int) error {
+	if len(lg.Logs) > MaxLogGroupCount {
+		return NewClientError(fmt.Sprintf("log group has %v logs, more than %v, see PutLogsChunked", len(lg.Logs), MaxLogGroupCount))
+	}
+	if size > MaxLogGroupSize {
+		return NewClientError(fmt.Sprintf("log group has %v bytes, more than %v, see PutLogsChunked", size, MaxLogGroupSize))
+	}
+	return nil
+}
+
+// SplitLogGroup splits log group lg into chunks of at most maxCount logs
+// and maxSize bytes once marshaled, with the topic, source and a copy of the
+// tags of lg. The logs stay in order, and a log too large for any chunk is
+// alone in its chunk. Zero or negative limits mean MaxLogGroupCount and
+// MaxLogGroupSize.
+func SplitLogGroup(lg *LogGroup, maxSize, maxCount int) []*LogGroup {
+	if maxSize <= 0 || maxSize > MaxLogGroupSize {
+		maxSize = MaxLogGroupSize
+	}
+	if maxCount <= 0 || maxCount > MaxLogGroupCount {
+		maxCount = MaxLogGroupCount
+	}
+
+	// The size of a chunk is the size of its header, i.e. the topic, source
+	// and tags, plus the size of each of its logs as a field.
+	header := (&LogGroup{Reserved: lg.Reserved, Topic: lg.Topic, Source: lg.Source, LogTags: lg.LogTags}).Size()
+	var chunks []*LogGroup
+	var chunk *LogGroup
+	var size int
+	for _, l := range lg.Logs {
+		n := l.Size()
+		n += 1 + sovLog(uint64(n))
+		if chunk == nil || len(chunk.Logs) >= maxCount || size+n > maxSize {
+			chunk = &LogGroup{Reserved: lg.Reserved, Topic: lg.Topic, Source: lg.Source, LogTags: copyTags(lg.LogTags)}
+			chunks = append(chunks, chunk)
+			size = header
+		}
+		chunk.Logs = append(chunk.Logs, l)
+		size += n
+	}
+	return chunks
+}
+
+// copyTags returns a copy of tags, so the tags of a chunk can be changed
+// without changing those of the other chunks.
+func copyTags(tags []*LogTag) []*LogTag {
+	if tags == nil {
+		return nil
+	}
+	c := make([]*LogTag, len(tags))
+	for i, t := range tags {
+		c[i] = &LogTag{Key: t.Key, Value: t.Value}
+	}
+	return c
+}
+
+// ChunkResult is the result of putting a chunk of a log group.
+type ChunkResult struct {
+	Offset int   // Index in the log group of the first log of the chunk
+	Count  int   // Number of logs in the chunk
+	Size   int   // Size in bytes of the marshaled chunk
+	Err    error // Error putting the chunk, nil if it was put
+}
+
+// PutLogsResult is the result of PutLogsChunked, with the chunks in the
+// order of their logs.
+type PutLogsResult struct {
+	Chunks []*ChunkResult
+}
+
+// Failed returns the chunks which couldn't be put.
+func (r *PutLogsResult) Failed() []*ChunkResult {
+	var failed []*ChunkResult
+	for _, c := range r.Chunks {
+		if c.Err != nil {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,146 @@
+package sls_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	sls "github.com/galaxydi/go-loghub"
+	"github.com/galaxydi/go-loghub/slstest"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/suite"
+)
+
+func valueLog(value string) *sls.Log {
+	return &sls.Log{
+		Time:     proto.Uint32(1500000000),
+		Contents: []*sls.LogContent{{Key: proto.String("key"), Value: proto.String(value)}},
+	}
+}
+
+func TestSplitLogGroup(t *testing.T) {
+	lg := &sls.LogGroup{Topic: proto.String("topic"), Source: proto.String("source")}
+	for i := 0; i < 10; i++ {
+		lg.Logs = append(lg.Logs, valueLog(strings.Repeat("x", 100)))
+	}
+	lg.Logs[5] = valueLog(strings.Repeat("x", 1000))
+
+	for _, limits := range [][2]int{{0, 0}, {0, 3}, {500, 0}, {500, 2}} {
+		maxSize, maxCount := limits[0], limits[1]
+		chunks := sls.SplitLogGroup(lg, maxSize, maxCount)
+		var logs []*sls.Log
+		for _, chunk := range chunks {
+			if chunk.GetTopic() != "topic" || chunk.GetSource() != "source" {
+				t.Errorf("%v: topic and source aren't kept: %v %v", limits, chunk.GetTopic(), chunk.GetSource())
+			}
+			if maxCount > 0 && len(chunk.Logs) > maxCount {
+				t.Errorf("%v: chunk has %v logs", limits, len(chunk.Logs))
+			}
+			if maxSize > 0 && chunk.Size() > maxSize && len(chunk.Logs) > 1 {
+				t.Errorf("%v: chunk has %v bytes", limits, chunk.Size())
+			}
+			logs = append(logs, chunk.Logs...)
+		}
+		if len(logs) != len(lg.Logs) {
+			t.Fatalf("%v: chunks have %v logs", limits, len(logs))
+		}
+		for i := range logs {
+			if logs[i] != lg.Logs[i] {
+				t.Errorf("%v: log %v isn't in order", limits, i)
+			}
+		}
+	}
+
+	if chunks := sls.SplitLogGroup(lg, 500, 0); len(chunks) != 4 {
+		t.Errorf("unexpected %v chunks", len(chunks))
+	}
+}
+
+func TestSplitLogGroupTags(t *testing.T) {
+	lg := &sls.LogGroup{Logs: []*sls.Log{valueLog("a"), valueLog("b"), valueLog("c")}}
+	lg.SetTag(sls.TagHostname, "host")
+	lg.SetTag(sls.TagPackID, "pack")
+	lg.SetTag(sls.TagPath, "path")
+
+	chunks := sls.SplitLogGroup(lg, 0, 1)
+	if len(chunks) != 3 {
+		t.Fatalf("unexpected %v chunks", len(chunks))
+	}
+	chunks[0].DeleteTag(sls.TagHostname)
+	chunks[1].SetTag(sls.TagPackID, "pack-1")
+	want := map[string]string{sls.TagHostname: "host", sls.TagPackID: "pack", sls.TagPath: "path"}
+	for _, g := range []*sls.LogGroup{lg, chunks[2]} {
+		if tags := g.Tags(); !reflect.DeepEqual(tags, want) {
+			t.Errorf("tags changed by another chunk: %v", tags)
+		}
+	}
+}
+
+func TestPutLogsChunked(t *testing.T) {
+	suite.Run(t, new(PutLogsChunkedTestSuite))
+}
+
+type PutLogsChunkedTestSuite struct {
+	suite.Suite
+	store *sls.LogStore
+}
+
+func (s *PutLogsChunkedTestSuite) SetupTest() {
+	_, s.store = slstest.NewLogStore(s.T(), 1)
+}
+
+// pulled returns the logs of the log groups pulled, and their topics.
+func (s *PutLogsChunkedTestSuite) pulled() (logs []*sls.Log, topics []string) {
+	for _, lg := range slstest.PullLogs(s.T(), s.store, 0) {
+		logs = append(logs, lg.Logs...)
+		topics = append(topics, lg.GetTopic())
+	}
+	return logs, topics
+}
+
+func (s *PutLogsChunkedTestSuite) TestTooManyLogs() {
+	lg := &sls.LogGroup{Topic: proto.String("topic")}
+	for i := 0; i < 2*sls.MaxLogGroupCount+1; i++ {
+		lg.Logs = append(lg.Logs, valueLog("value"))
+	}
+
+	err := s.store.PutLogs(lg)
+	slsErr, ok := err.(*sls.Error)
+	s.True(ok)
+	if ok {
+		s.Equal("ClientError", slsErr.Code)
+	}
+
+	result, err := s.store.PutLogsChunked(lg, 2)
+	s.Nil(err)
+	s.Len(result.Chunks, 3)
+	s.Empty(result.Failed())
+	s.Equal(2*sls.MaxLogGroupCount, result.Chunks[2].Offset)
+	s.Equal(1, result.Chunks[2].Count)
+
+	logs, topics := s.pulled()
+	s.Len(logs, len(lg.Logs))
+	s.Equal([]string{"topic", "topic", "topic"}, topics)
+}
+
+func (s *PutLogsChunkedTestSuite) TestTooLargeLog() {
+	lg := &sls.LogGroup{Logs: []*sls.Log{
+		valueLog("first"),
+		valueLog(strings.Repeat("x", sls.MaxLogGroupSize)),
+		valueLog("last"),
+	}}
+
+	result, err := s.store.PutLogsChunked(lg, 1)
+	s.True(err != nil)
+	s.Len(result.Chunks, 3)
+	failed := result.Failed()
+	s.Len(failed, 1)
+	if len(failed) == 1 {
+		s.Equal(1, failed[0].Offset)
+		s.Equal(1, failed[0].Count)
+		s.Equal(err, failed[0].Err)
+	}
+
+	logs, _ := s.pulled()
+	s.Len(logs, 2)
+}
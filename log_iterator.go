@@ -0,0 +1,407 @@
+package sls
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// wireField is a field of a marshaled protobuf message.
+type wireField struct {
+	num   int
+	typ   int
+	value uint64 // Value of the varint and fixed fields
+	bytes []byte // Value of the length-delimited fields
+}
+
+// nextField reads the field at the start of data, and returns the rest.
+func nextField(data []byte) (f wireField, rest []byte, err error) {
+	key, n := binary.Uvarint(data)
+	if n <= 0 {
+		return f, nil, varintError(n)
+	}
+	data = data[n:]
+	f.num, f.typ = int(key>>3), int(key&0x7)
+	if f.num <= 0 {
+		return f, nil, fmt.Errorf("proto: illegal tag %d (wire type %d)", f.num, f.typ)
+	}
+	switch f.typ {
+	case 0:
+		if f.value, n = binary.Uvarint(data); n <= 0 {
+			return f, nil, varintError(n)
+		}
+		data = data[n:]
+	case 1:
+		if len(data) < 8 {
+			return f, nil, io.ErrUnexpectedEOF
+		}
+		f.value = binary.LittleEndian.Uint64(data)
+		data = data[8:]
+	case 2:
+		length, n := binary.Uvarint(data)
+		if n <= 0 {
+			return f, nil, varintError(n)
+		}
+		data = data[n:]
+		if length > uint64(len(data)) {
+			return f, nil, io.ErrUnexpectedEOF
+		}
+		f.bytes = data[:length]
+		data = data[length:]
+	case 5:
+		if len(data) < 4 {
+			return f, nil, io.ErrUnexpectedEOF
+		}
+		f.value = uint64(binary.LittleEndian.Uint32(data))
+		data = data[4:]
+	default:
+		return f, nil, fmt.Errorf("proto: illegal wireType %d", f.typ)
+	}
+	return f, data, nil
+}
+
+// varintError returns the error of binary.Uvarint returning n <= 0.
+func varintError(n int) error {
+	if n == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return ErrIntOverflowLog
+}
+
+// LogGroupIterator iterates over the log groups of the data returned by
+// GetLogsBytes without unmarshaling them. The log groups, logs, contents
+// and tags it returns are slices of the data, they're only valid as long
+// as the data isn't modified and should be copied to be kept. It's much
+// lighter than LogsBytesDecode for consumers reading a few contents:
+//
+//	it := sls.NewLogGroupIterator(data)
+//	for it.Next() {
+//		logs := it.LogGroup().Logs()
+//		for logs.Next() {
+//			status, _ := logs.Log().Content("status")
+//		}
+//	}
+//	return it.Err()
+type LogGroupIterator struct {
+	data  []byte // Rest of the LogGroupList
+	group RawLogGroup
+	err   error
+}
+
+// NewLogGroupIterator returns an iterator over the log groups of data, a
+// LogGroupList returned by GetLogsBytes.
+func NewLogGroupIterator(data []byte) *LogGroupIterator {
+	return &LogGroupIterator{data: data}
+}
+
+// Next moves to the next log group, it returns false at the end of the
+// data or on an error.
+func (it *LogGroupIterator) Next() bool {
+	for it.err == nil && len(it.data) > 0 {
+		f, rest, err := nextField(it.data)
+		if err != nil {
+			it.err = clientError(err)
+			return false
+		}
+		it.data = rest
+		if f.num == 1 && f.typ == 2 {
+			if it.group, err = parseLogGroup(f.bytes); err != nil {
+				it.err = clientError(err)
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// LogGroup returns the current log group.
+func (it *LogGroupIterator) LogGroup() RawLogGroup {
+	return it.group
+}
+
+// Err returns the error which stopped the iteration, nil at the end of
+// the data.
+func (it *LogGroupIterator) Err() error {
+	return it.err
+}
+
+// RawLogGroup is a marshaled LogGroup.
+type RawLogGroup struct {
+	data   []byte
+	topic  []byte
+	source []byte
+}
+
+func parseLogGroup(data []byte) (g RawLogGroup, err error) {
+	g.data = data
+	for len(data) > 0 {
+		var f wireField
+		if f, data, err = nextField(data); err != nil {
+			return g, err
+		}
+		switch {
+		case f.num == 3 && f.typ == 2:
+			g.topic = f.bytes
+		case f.num == 4 && f.typ == 2:
+			g.source = f.bytes
+		}
+	}
+	return g, nil
+}
+
+// Topic returns the topic of log group g.
+func (g RawLogGroup) Topic() []byte {
+	return g.topic
+}
+
+// Source returns the source of log group g.
+func (g RawLogGroup) Source() []byte {
+	return g.source
+}
+
+// Logs returns an iterator over the logs of log group g.
+func (g RawLogGroup) Logs() LogIterator {
+	return LogIterator{data: g.data}
+}
+
+// Tags returns an iterator over the tags of log group g.
+func (g RawLogGroup) Tags() ContentIterator {
+	return ContentIterator{data: g.data, num: 6}
+}
+
+// LogGroup unmarshals log group g, with only the contents of its logs with
+// keys if keys isn't empty.
+func (g RawLogGroup) LogGroup(keys ...string) (*LogGroup, error) {
+	lg := &LogGroup{}
+	if g.topic != nil {
+		lg.Topic = bytesString(g.topic)
+	}
+	if g.source != nil {
+		lg.Source = bytesString(g.source)
+	}
+	tags := g.Tags()
+	for tags.Next() {
+		lg.LogTags = append(lg.LogTags, &LogTag{Key: bytesString(tags.Key()), Value: bytesString(tags.Value())})
+	}
+	if err := tags.Err(); err != nil {
+		return nil, err
+	}
+	logs := g.Logs()
+	for logs.Next() {
+		l, err := logs.Log().Log(keys...)
+		if err != nil {
+			return nil, err
+		}
+		lg.Logs = append(lg.Logs, l)
+	}
+	if err := logs.Err(); err != nil {
+		return nil, err
+	}
+	return lg, nil
+}
+
+// LogIterator iterates over the logs of a RawLogGroup.
+type LogIterator struct {
+	data []byte // Rest of the LogGroup
+	log  RawLog
+	err  error
+}
+
+// Next moves to the next log, it returns false at the end of the log group
+// or on an error.
+func (it *LogIterator) Next() bool {
+	for it.err == nil && len(it.data) > 0 {
+		f, rest, err := nextField(it.data)
+		if err != nil {
+			it.err = clientError(err)
+			return false
+		}
+		it.data = rest
+		if f.num == 1 && f.typ == 2 {
+			if it.log, err = parseLog(f.bytes); err != nil {
+				it.err = clientError(err)
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Log returns the current log.
+func (it *LogIterator) Log() RawLog {
+	return it.log
+}
+
+// Err returns the error which stopped the iteration, nil at the end of
+// the log group.
+func (it *LogIterator) Err() error {
+	return it.err
+}
+
+// RawLog is a marshaled Log.
+type RawLog struct {
+	data      []byte
+	time      uint32
+	timeNs    uint32
+	hasTimeNs bool
+}
+
+func parseLog(data []byte) (l RawLog, err error) {
+	l.data = data
+	hasTime := false
+	for len(data) > 0 {
+		var f wireField
+		if f, data, err = nextField(data); err != nil {
+			return l, err
+		}
+		switch {
+		case f.num == 1 && f.typ == 0:
+			l.time = uint32(f.value)
+			hasTime = true
+		case f.num == 4 && f.typ == 5:
+			l.timeNs = uint32(f.value)
+			l.hasTimeNs = true
+		}
+	}
+	if !hasTime {
+		return l, proto.NewRequiredNotSetError("Time")
+	}
+	return l, nil
+}
+
+// Time returns the time of log l.
+func (l RawLog) Time() uint32 {
+	return l.time
+}
+
+// TimeNs returns the nanosecond part of the time of log l.
+func (l RawLog) TimeNs() uint32 {
+	return l.timeNs
+}
+
+// Contents returns an iterator over the contents of log l.
+func (l RawLog) Contents() ContentIterator {
+	return ContentIterator{data: l.data, num: 2}
+}
+
+// Content returns the value of the first content of log l with key, ok is
+// false if there's none or if the contents are malformed.
+func (l RawLog) Content(key string) (value []byte, ok bool) {
+	contents := l.Contents()
+	for contents.Next() {
+		if string(contents.Key()) == key {
+			return contents.Value(), true
+		}
+	}
+	return nil, false
+}
+
+// Log unmarshals log l, with only its contents with keys if keys isn't
+// empty.
+func (l RawLog) Log(keys ...string) (*Log, error) {
+	log := &Log{Time: &l.time}
+	if l.hasTimeNs {
+		log.TimeNs = &l.timeNs
+	}
+	contents := l.Contents()
+	for contents.Next() {
+		if len(keys) > 0 && !containsKey(keys, contents.Key()) {
+			continue
+		}
+		log.Contents = append(log.Contents, &LogContent{
+			Key:   bytesString(contents.Key()),
+			Value: bytesString(contents.Value()),
+		})
+	}
+	if err := contents.Err(); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+func containsKey(keys []string, key []byte) bool {
+	for _, k := range keys {
+		if string(key) == k {
+			return true
+		}
+	}
+	return false
+}
+
+func bytesString(b []byte) *string {
+	s := string(b)
+	return &s
+}
+
+// ContentIterator iterates over the contents of a RawLog, or over the tags
+// of a RawLogGroup.
+type ContentIterator struct {
+	data  []byte // Rest of the Log or LogGroup
+	num   int    // Field number of the contents
+	key   []byte
+	value []byte
+	err   error
+}
+
+// Next moves to the next content, it returns false at the end of the log
+// or on an error.
+func (it *ContentIterator) Next() bool {
+	for it.err == nil && len(it.data) > 0 {
+		f, rest, err := nextField(it.data)
+		if err != nil {
+			it.err = clientError(err)
+			return false
+		}
+		it.data = rest
+		if f.num == it.num && f.typ == 2 {
+			if it.key, it.value, err = parseContent(f.bytes); err != nil {
+				it.err = clientError(err)
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func parseContent(data []byte) (key, value []byte, err error) {
+	for len(data) > 0 {
+		var f wireField
+		if f, data, err = nextField(data); err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case f.num == 1 && f.typ == 2:
+			key = f.bytes
+		case f.num == 2 && f.typ == 2:
+			value = f.bytes
+		}
+	}
+	if key == nil {
+		return nil, nil, proto.NewRequiredNotSetError("Key")
+	}
+	if value == nil {
+		return nil, nil, proto.NewRequiredNotSetError("Value")
+	}
+	return key, value, nil
+}
+
+// Key returns the key of the current content.
+func (it *ContentIterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value of the current content.
+func (it *ContentIterator) Value() []byte {
+	return it.value
+}
+
+// Err returns the error which stopped the iteration, nil at the end of
+// the log.
+func (it *ContentIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,197 @@
+package sls
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// newTestLogGroupList returns a LogGroupList of groups log groups of logs
+// logs, each with 10 contents.
+func newTestLogGroupList(groups, logs int) *LogGroupList {
+	gl := &LogGroupList{}
+	for i := 0; i < groups; i++ {
+		lg := &LogGroup{
+			Topic:  proto.String(fmt.Sprintf("topic-%v", i)),
+			Source: proto.String("10.0.0.1"),
+		}
+		lg.SetTag(TagHostname, "host-1")
+		for j := 0; j < logs; j++ {
+			l := &Log{Time: proto.Uint32(uint32(1500000000 + j))}
+			if j%2 == 0 {
+				l.TimeNs = proto.Uint32(uint32(j))
+			}
+			for k := 0; k < 10; k++ {
+				l.Contents = append(l.Contents, &LogContent{
+					Key:   proto.String(fmt.Sprintf("key-%v", k)),
+					Value: proto.String(fmt.Sprintf("value-%v-%v", j, k)),
+				})
+			}
+			lg.Logs = append(lg.Logs, l)
+		}
+		gl.LogGroups = append(gl.LogGroups, lg)
+	}
+	return gl
+}
+
+func TestLogGroupIterator(t *testing.T) {
+	gl := newTestLogGroupList(3, 5)
+	data, err := gl.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := NewLogGroupIterator(data)
+	var groups []*LogGroup
+	for it.Next() {
+		g := it.LogGroup()
+		lg, err := g.LogGroup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		groups = append(groups, lg)
+
+		logs := g.Logs()
+		for logs.Next() {
+			l := logs.Log()
+			value, ok := l.Content("key-3")
+			if expected := fmt.Sprintf("value-%v-3", l.Time()-1500000000); !ok || string(value) != expected {
+				t.Errorf("unexpected value %q", value)
+			}
+			if _, ok := l.Content("missing"); ok {
+				t.Error("unexpected content")
+			}
+		}
+		if logs.Err() != nil {
+			t.Fatal(logs.Err())
+		}
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if !reflect.DeepEqual(groups, gl.LogGroups) {
+		t.Errorf("unexpected log groups %v", groups)
+	}
+
+	it = NewLogGroupIterator(data)
+	it.Next()
+	lg, err := it.LogGroup().LogGroup("key-1", "key-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := lg.Logs[0]
+	if len(l.Contents) != 2 || l.Contents[0].GetKey() != "key-1" || l.Contents[1].GetKey() != "key-2" {
+		t.Errorf("unexpected log %v", l)
+	}
+}
+
+func TestLogGroupIteratorMalformed(t *testing.T) {
+	data, err := newTestLogGroupList(1, 2).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 2, 10, len(data) - 1} {
+		it := NewLogGroupIterator(data[:n])
+		for it.Next() {
+		}
+		if it.Err() == nil {
+			t.Errorf("no error for %v bytes", n)
+		}
+	}
+
+	// A log without time.
+	data, err = (&LogGroupList{LogGroups: []*LogGroup{{
+		XXXUnrecognized: []byte{0xa, 0x0},
+	}}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := NewLogGroupIterator(data)
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	logs := it.LogGroup().Logs()
+	if logs.Next() || logs.Err() == nil {
+		t.Error("no error for a log without time")
+	}
+}
+
+func TestLogGroupIteratorAllocs(t *testing.T) {
+	data, err := newTestLogGroupList(3, 10).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := &LogGroupIterator{}
+	allocs := testing.AllocsPerRun(10, func() {
+		*it = LogGroupIterator{data: data}
+		for it.Next() {
+			logs := it.LogGroup().Logs()
+			for logs.Next() {
+				contents := logs.Log().Contents()
+				for contents.Next() {
+				}
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations", allocs)
+	}
+}
+
+func BenchmarkLogsBytesDecode(b *testing.B) {
+	data, _ := newTestLogGroupList(10, 100).Marshal()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gl, err := LogsBytesDecode(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, lg := range gl.LogGroups {
+			for _, l := range lg.Logs {
+				for _, c := range l.Contents {
+					if c.GetKey() == "key-3" {
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkLogGroupIterator(b *testing.B) {
+	data, _ := newTestLogGroupList(10, 100).Marshal()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := NewLogGroupIterator(data)
+		for it.Next() {
+			logs := it.LogGroup().Logs()
+			for logs.Next() {
+				logs.Log().Content("key-3")
+			}
+		}
+		if it.Err() != nil {
+			b.Fatal(it.Err())
+		}
+	}
+}
+
+func BenchmarkLogGroupIteratorSelectedKeys(b *testing.B) {
+	data, _ := newTestLogGroupList(10, 100).Marshal()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := NewLogGroupIterator(data)
+		for it.Next() {
+			if _, err := it.LogGroup().LogGroup("key-3"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
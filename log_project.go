@@ -1,19 +1,76 @@
 package sls
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 // LogProject defines log project
 type LogProject struct {
-	Name            string // Project name
-	Endpoint        string // IP or hostname of SLS endpoint
+	Name string // Project name
+
+	// Endpoint is the IP or hostname of SLS endpoint, with an optional
+	// port, e.g. "cn-hangzhou.log.aliyuncs.com", using https, or a URL
+	// like "http://cn-hangzhou-intranet.log.aliyuncs.com:80".
+	Endpoint string
+
+	// ProjectInHeader addresses the project with the 'x-log-project' header
+	// instead of the '<project>.<endpoint>' virtual host, e.g. for proxies
+	// or stand-in servers. It's implied when the endpoint host is an IP
+	// address or localhost.
+	ProjectInHeader bool
+
+	// SignatureVersion selects how requests are signed, empty means SignatureV1.
+	// SignatureV4 is experimental. It needs the Region of the project, which
+	// is found from the endpoint if empty, e.g. "cn-hangzhou" for
+	// cn-hangzhou.log.aliyuncs.com.
+	SignatureVersion SignatureVersion
+	Region           string
+
 	AccessKeyID     string
 	AccessKeySecret string
 	SecurityToken   string
+
+	// CredentialsProvider provides the credentials signing the requests of
+	// this project. A nil CredentialsProvider means the static AccessKeyID,
+	// AccessKeySecret and SecurityToken, which are updated safely by WithToken.
+	CredentialsProvider CredentialsProvider
+	mu                  sync.RWMutex // Protects the credentials
+
+	// HTTPClient sends the requests of this project.
+	// A nil HTTPClient means defaultHTTPClient.
+	HTTPClient *http.Client
+
+	// RetryPolicy decides which failed requests are retried.
+	// A nil RetryPolicy means DefaultRetryPolicy.
+	RetryPolicy RetryPolicy
+
+	// Interceptors intercept the attempts of the requests of this project,
+	// in order, see Interceptor.
+	Interceptors []Interceptor
+
+	// Metrics records the requests of this project, nil means no metrics.
+	Metrics Metrics
+
+	// Tracer traces the requests of this project, nil means no tracing.
+	Tracer Tracer
+
+	// RateLimiter throttles the requests of this project, nil means no
+	// client side limit.
+	RateLimiter *RateLimiter
+
+	// EndpointPool, if any, is used instead of Endpoint to fail over to
+	// other endpoints, see WithEndpoints.
+	EndpointPool *EndpointPool
+
+	// Logger logs the requests of this project, nil means no logs.
+	Logger Logger
 }
 
 // NewLogProject creates a new SLS project.
@@ -27,22 +84,176 @@ func NewLogProject(name, endpoint, accessKeyID, accessKeySecret string) (p *LogP
 	return p, nil
 }
 
-// WithToken add token parameter
+// WithToken add token parameter. It's safe to call while requests are
+// sent, e.g. to rotate an STS token.
 func (p *LogProject) WithToken(token string) (*LogProject, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.SecurityToken = token
 	return p, nil
 }
 
+// WithCredentialsProvider sets the provider of the credentials signing requests.
+func (p *LogProject) WithCredentialsProvider(provider CredentialsProvider) (*LogProject, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.CredentialsProvider = provider
+	return p, nil
+}
+
+// credentials returns the credentials signing the next request of project p.
+func (p *LogProject) credentials(ctx context.Context) (Credentials, error) {
+	p.mu.RLock()
+	provider := p.CredentialsProvider
+	creds := Credentials{
+		AccessKeyID:     p.AccessKeyID,
+		AccessKeySecret: p.AccessKeySecret,
+		SecurityToken:   p.SecurityToken,
+	}
+	p.mu.RUnlock()
+
+	if provider != nil {
+		return provider.Credentials(ctx)
+	}
+	return creds, nil
+}
+
+// WithProjectInHeader sets whether the project is addressed with the
+// 'x-log-project' header instead of the virtual host.
+func (p *LogProject) WithProjectInHeader(inHeader bool) (*LogProject, error) {
+	p.ProjectInHeader = inHeader
+	return p, nil
+}
+
+// projectInHeader tells whether the requests of project p to endpoint host
+// address the project with a header.
+func (p *LogProject) projectInHeader(host string) bool {
+	if p.ProjectInHeader {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	return host == "localhost" || net.ParseIP(host) != nil
+}
+
+// WithSignatureVersion sets how requests are signed.
+func (p *LogProject) WithSignatureVersion(version SignatureVersion) (*LogProject, error) {
+	p.SignatureVersion = version
+	return p, nil
+}
+
+// WithRegion sets the region of the project used by SignatureV4.
+func (p *LogProject) WithRegion(region string) (*LogProject, error) {
+	p.Region = region
+	return p, nil
+}
+
+// region returns the region of project p, whose endpoint host is host.
+func (p *LogProject) region(host string) (string, error) {
+	if p.Region != "" {
+		return p.Region, nil
+	}
+	return regionOf(host)
+}
+
+// WithHTTPClient sets the HTTP client used to send requests,
+// e.g. to change timeouts, proxies, TLS roots or the transport.
+func (p *LogProject) WithHTTPClient(client *http.Client) (*LogProject, error) {
+	p.HTTPClient = client
+	return p, nil
+}
+
+// httpClient returns the HTTP client used to send requests of project p.
+func (p *LogProject) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return defaultHTTPClient
+}
+
+// WithRetryPolicy sets the policy deciding which failed requests are retried.
+func (p *LogProject) WithRetryPolicy(policy RetryPolicy) (*LogProject, error) {
+	p.RetryPolicy = policy
+	return p, nil
+}
+
+// WithInterceptors sets the interceptors of the requests of project p.
+func (p *LogProject) WithInterceptors(interceptors ...Interceptor) (*LogProject, error) {
+	p.Interceptors = interceptors
+	return p, nil
+}
+
+// WithMetrics sets the recorder of the requests of project p, e.g. a
+// MetricsRegistry.
+func (p *LogProject) WithMetrics(metrics Metrics) (*LogProject, error) {
+	p.Metrics = metrics
+	return p, nil
+}
+
+// WithTracer sets the tracer of the requests of project p.
+func (p *LogProject) WithTracer(tracer Tracer) (*LogProject, error) {
+	p.Tracer = tracer
+	return p, nil
+}
+
+// WithRateLimiter sets the limiter throttling the requests of project p.
+func (p *LogProject) WithRateLimiter(limiter *RateLimiter) (*LogProject, error) {
+	p.RateLimiter = limiter
+	return p, nil
+}
+
+// WithEndpoints sets the endpoints of project p in order of preference,
+// e.g. RegionEndpoints("cn-hangzhou"), the requests fail over from one to
+// the next. Endpoint is set to the first one.
+func (p *LogProject) WithEndpoints(endpoints ...string) (*LogProject, error) {
+	if len(endpoints) == 0 {
+		return nil, NewClientError("no endpoint")
+	}
+	p.Endpoint = endpoints[0]
+	p.EndpointPool = NewEndpointPool(endpoints...)
+	return p, nil
+}
+
+// WithLogger sets the logger of the requests of project p, e.g. NewSlogLogger(slog.Default()).
+func (p *LogProject) WithLogger(logger Logger) (*LogProject, error) {
+	p.Logger = logger
+	return p, nil
+}
+
+// endpoint returns the endpoint of the next attempt of a request, failed
+// is the endpoint of the previous attempt if it failed because of it.
+func (p *LogProject) endpoint(failed string) string {
+	if p.EndpointPool == nil || len(p.EndpointPool.Endpoints) == 0 {
+		return p.Endpoint
+	}
+	return p.EndpointPool.pick(failed)
+}
+
+// retryPolicy returns the retry policy of project p.
+func (p *LogProject) retryPolicy() RetryPolicy {
+	if p.RetryPolicy != nil {
+		return p.RetryPolicy
+	}
+	return DefaultRetryPolicy
+}
+
 // ListLogStore returns all logstore names of project p.
 func (p *LogProject) ListLogStore() ([]string, error) {
+	return p.ListLogStoreWithContext(context.Background())
+}
+
+// ListLogStoreWithContext is like ListLogStore but uses ctx to cancel the request.
+func (p *LogProject) ListLogStoreWithContext(ctx context.Context) ([]string, error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 
 	uri := fmt.Sprintf("/logstores")
-	r, err := request(p, "GET", uri, h, nil)
+	r, err := request(ctx, p, "ListLogStore", "GET", uri, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -64,13 +275,18 @@ func (p *LogProject) ListLogStore() ([]string, error) {
 
 // GetLogStore returns logstore according by logstore name.
 func (p *LogProject) GetLogStore(name string) (*LogStore, error) {
+	return p.GetLogStoreWithContext(context.Background(), name)
+}
+
+// GetLogStoreWithContext is like GetLogStore but uses ctx to cancel the request.
+func (p *LogProject) GetLogStoreWithContext(ctx context.Context, name string) (*LogStore, error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 
-	r, err := request(p, "GET", "/logstores/"+name, h, nil)
+	r, err := request(ctx, p, "GetLogStore", "GET", "/logstores/"+name, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -92,6 +308,11 @@ func (p *LogProject) GetLogStore(name string) (*LogStore, error) {
 // and ttl is time-to-live(in day) of logs,
 // and shardCnt is the number of shards.
 func (p *LogProject) CreateLogStore(name string, ttl, shardCnt int) error {
+	return p.CreateLogStoreWithContext(context.Background(), name, ttl, shardCnt)
+}
+
+// CreateLogStoreWithContext is like CreateLogStore but uses ctx to cancel the request.
+func (p *LogProject) CreateLogStoreWithContext(ctx context.Context, name string, ttl, shardCnt int) error {
 	type Body struct {
 		Name       string `json:"logstoreName"`
 		TTL        int    `json:"ttl"`
@@ -104,18 +325,18 @@ func (p *LogProject) CreateLogStore(name string, ttl, shardCnt int) error {
 	}
 	body, err := json.Marshal(store)
 	if err != nil {
-		return NewClientError(err.Error())
+		return p.opError(err, "CreateLogStore", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+		"Accept-Encoding":   DefaultCompressType,
 	}
 
-	r, err := request(p, "POST", "/logstores", h, body)
+	r, err := request(ctx, p, "CreateLogStore", "POST", "/logstores", h, body)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ = ioutil.ReadAll(r.Body)
@@ -129,13 +350,18 @@ func (p *LogProject) CreateLogStore(name string, ttl, shardCnt int) error {
 
 // DeleteLogStore deletes a logstore according by logstore name.
 func (p *LogProject) DeleteLogStore(name string) (err error) {
+	return p.DeleteLogStoreWithContext(context.Background(), name)
+}
+
+// DeleteLogStoreWithContext is like DeleteLogStore but uses ctx to cancel the request.
+func (p *LogProject) DeleteLogStoreWithContext(ctx context.Context, name string) (err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 
-	r, err := request(p, "DELETE", "/logstores/"+name, h, nil)
+	r, err := request(ctx, p, "DeleteLogStore", "DELETE", "/logstores/"+name, h, nil)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ := ioutil.ReadAll(r.Body)
@@ -150,6 +376,11 @@ func (p *LogProject) DeleteLogStore(name string) (err error) {
 // UpdateLogStore updates a logstore according by logstore name,
 // obviously we can't modify the logstore name itself.
 func (p *LogProject) UpdateLogStore(name string, ttl, shardCnt int) (err error) {
+	return p.UpdateLogStoreWithContext(context.Background(), name, ttl, shardCnt)
+}
+
+// UpdateLogStoreWithContext is like UpdateLogStore but uses ctx to cancel the request.
+func (p *LogProject) UpdateLogStoreWithContext(ctx context.Context, name string, ttl, shardCnt int) (err error) {
 	type Body struct {
 		Name       string `json:"logstoreName"`
 		TTL        int    `json:"ttl"`
@@ -162,17 +393,17 @@ func (p *LogProject) UpdateLogStore(name string, ttl, shardCnt int) (err error)
 	}
 	body, err := json.Marshal(store)
 	if err != nil {
-		return NewClientError(err.Error())
+		return p.opError(err, "UpdateLogStore", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+		"Accept-Encoding":   DefaultCompressType,
 	}
-	r, err := request(p, "PUT", "/logstores/"+name, h, body)
+	r, err := request(ctx, p, "UpdateLogStore", "PUT", "/logstores/"+name, h, body)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ = ioutil.ReadAll(r.Body)
@@ -187,6 +418,11 @@ func (p *LogProject) UpdateLogStore(name string, ttl, shardCnt int) (err error)
 // ListMachineGroup returns machine group name list and the total number of machine groups.
 // The offset starts from 0 and the size is the max number of machine groups could be returned.
 func (p *LogProject) ListMachineGroup(offset, size int) (m []string, total int, err error) {
+	return p.ListMachineGroupWithContext(context.Background(), offset, size)
+}
+
+// ListMachineGroupWithContext is like ListMachineGroup but uses ctx to cancel the request.
+func (p *LogProject) ListMachineGroupWithContext(ctx context.Context, offset, size int) (m []string, total int, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
@@ -194,9 +430,9 @@ func (p *LogProject) ListMachineGroup(offset, size int) (m []string, total int,
 		size = 500
 	}
 	uri := fmt.Sprintf("/machinegroups?offset=%v&size=%v", offset, size)
-	r, err := request(p, "GET", uri, h, nil)
+	r, err := request(ctx, p, "ListMachineGroup", "GET", uri, h, nil)
 	if err != nil {
-		return nil, 0, NewClientError(err.Error())
+		return nil, 0, clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -220,10 +456,15 @@ func (p *LogProject) ListMachineGroup(offset, size int) (m []string, total int,
 
 // CheckLogstoreExist check logstore exist or not
 func (p *LogProject) CheckLogstoreExist(name string) (bool, error) {
+	return p.CheckLogstoreExistWithContext(context.Background(), name)
+}
+
+// CheckLogstoreExistWithContext is like CheckLogstoreExist but uses ctx to cancel the request.
+func (p *LogProject) CheckLogstoreExistWithContext(ctx context.Context, name string) (bool, error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
-	_, err := request(p, "GET", "/logstores/"+name, h, nil)
+	_, err := request(ctx, p, "CheckLogstoreExist", "GET", "/logstores/"+name, h, nil)
 	if err != nil {
 		if _, ok := err.(*Error); ok {
 			slsErr := err.(*Error)
@@ -239,10 +480,15 @@ func (p *LogProject) CheckLogstoreExist(name string) (bool, error) {
 
 // CheckMachineGroupExist check machine group exist or not
 func (p *LogProject) CheckMachineGroupExist(name string) (bool, error) {
+	return p.CheckMachineGroupExistWithContext(context.Background(), name)
+}
+
+// CheckMachineGroupExistWithContext is like CheckMachineGroupExist but uses ctx to cancel the request.
+func (p *LogProject) CheckMachineGroupExistWithContext(ctx context.Context, name string) (bool, error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
-	_, err := request(p, "GET", "/machinegroups/"+name, h, nil)
+	_, err := request(ctx, p, "CheckMachineGroupExist", "GET", "/machinegroups/"+name, h, nil)
 
 	if err != nil {
 		if _, ok := err.(*Error); ok {
@@ -259,12 +505,17 @@ func (p *LogProject) CheckMachineGroupExist(name string) (bool, error) {
 
 // GetMachineGroup retruns machine group according by machine group name.
 func (p *LogProject) GetMachineGroup(name string) (m *MachineGroup, err error) {
+	return p.GetMachineGroupWithContext(context.Background(), name)
+}
+
+// GetMachineGroupWithContext is like GetMachineGroup but uses ctx to cancel the request.
+func (p *LogProject) GetMachineGroupWithContext(ctx context.Context, name string) (m *MachineGroup, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
-	resp, err := request(p, "GET", "/machinegroups/"+name, h, nil)
+	resp, err := request(ctx, p, "GetMachineGroup", "GET", "/machinegroups/"+name, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(resp.Body)
@@ -282,19 +533,24 @@ func (p *LogProject) GetMachineGroup(name string) (m *MachineGroup, err error) {
 
 // CreateMachineGroup creates a new machine group in SLS.
 func (p *LogProject) CreateMachineGroup(m *MachineGroup) error {
+	return p.CreateMachineGroupWithContext(context.Background(), m)
+}
+
+// CreateMachineGroupWithContext is like CreateMachineGroup but uses ctx to cancel the request.
+func (p *LogProject) CreateMachineGroupWithContext(ctx context.Context, m *MachineGroup) error {
 	body, err := json.Marshal(m)
 	if err != nil {
-		return NewClientError(err.Error())
+		return p.opError(err, "CreateMachineGroup", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+		"Accept-Encoding":   DefaultCompressType,
 	}
-	resp, err := request(p, "POST", "/machinegroups", h, body)
+	resp, err := request(ctx, p, "CreateMachineGroup", "POST", "/machinegroups", h, body)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ = ioutil.ReadAll(resp.Body)
@@ -308,19 +564,24 @@ func (p *LogProject) CreateMachineGroup(m *MachineGroup) error {
 
 // UpdateMachineGroup updates a machine group.
 func (p *LogProject) UpdateMachineGroup(m *MachineGroup) (err error) {
+	return p.UpdateMachineGroupWithContext(context.Background(), m)
+}
+
+// UpdateMachineGroupWithContext is like UpdateMachineGroup but uses ctx to cancel the request.
+func (p *LogProject) UpdateMachineGroupWithContext(ctx context.Context, m *MachineGroup) (err error) {
 	body, err := json.Marshal(m)
 	if err != nil {
-		return NewClientError(err.Error())
+		return p.opError(err, "UpdateMachineGroup", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+		"Accept-Encoding":   DefaultCompressType,
 	}
-	r, err := request(p, "PUT", "/machinegroups/"+m.Name, h, body)
+	r, err := request(ctx, p, "UpdateMachineGroup", "PUT", "/machinegroups/"+m.Name, h, body)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ = ioutil.ReadAll(r.Body)
@@ -334,12 +595,17 @@ func (p *LogProject) UpdateMachineGroup(m *MachineGroup) (err error) {
 
 // DeleteMachineGroup deletes machine group according machine group name.
 func (p *LogProject) DeleteMachineGroup(name string) (err error) {
+	return p.DeleteMachineGroupWithContext(context.Background(), name)
+}
+
+// DeleteMachineGroupWithContext is like DeleteMachineGroup but uses ctx to cancel the request.
+func (p *LogProject) DeleteMachineGroupWithContext(ctx context.Context, name string) (err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
-	r, err := request(p, "DELETE", "/machinegroups/"+name, h, nil)
+	r, err := request(ctx, p, "DeleteMachineGroup", "DELETE", "/machinegroups/"+name, h, nil)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ := ioutil.ReadAll(r.Body)
@@ -355,6 +621,11 @@ func (p *LogProject) DeleteMachineGroup(name string) (err error) {
 // ListConfig returns config names list and the total number of configs.
 // The offset starts from 0 and the size is the max number of configs could be returned.
 func (p *LogProject) ListConfig(offset, size int) (cfgNames []string, total int, err error) {
+	return p.ListConfigWithContext(context.Background(), offset, size)
+}
+
+// ListConfigWithContext is like ListConfig but uses ctx to cancel the request.
+func (p *LogProject) ListConfigWithContext(ctx context.Context, offset, size int) (cfgNames []string, total int, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
@@ -362,9 +633,9 @@ func (p *LogProject) ListConfig(offset, size int) (cfgNames []string, total int,
 		size = 100
 	}
 	uri := fmt.Sprintf("/configs?offset=%v&size=%v", offset, size)
-	r, err := request(p, "GET", uri, h, nil)
+	r, err := request(ctx, p, "ListConfig", "GET", uri, h, nil)
 	if err != nil {
-		return nil, 0, NewClientError(err.Error())
+		return nil, 0, clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -387,10 +658,15 @@ func (p *LogProject) ListConfig(offset, size int) (cfgNames []string, total int,
 
 // CheckConfigExist check config exist or not
 func (p *LogProject) CheckConfigExist(name string) (bool, error) {
+	return p.CheckConfigExistWithContext(context.Background(), name)
+}
+
+// CheckConfigExistWithContext is like CheckConfigExist but uses ctx to cancel the request.
+func (p *LogProject) CheckConfigExistWithContext(ctx context.Context, name string) (bool, error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
-	_, err := request(p, "GET", "/configs/"+name, h, nil)
+	_, err := request(ctx, p, "CheckConfigExist", "GET", "/configs/"+name, h, nil)
 	if err != nil {
 		if _, ok := err.(*Error); ok {
 			slsErr := err.(*Error)
@@ -406,12 +682,17 @@ func (p *LogProject) CheckConfigExist(name string) (bool, error) {
 
 // GetConfig returns config according by config name.
 func (p *LogProject) GetConfig(name string) (c *LogConfig, err error) {
+	return p.GetConfigWithContext(context.Background(), name)
+}
+
+// GetConfigWithContext is like GetConfig but uses ctx to cancel the request.
+func (p *LogProject) GetConfigWithContext(ctx context.Context, name string) (c *LogConfig, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
-	r, err := request(p, "GET", "/configs/"+name, h, nil)
+	r, err := request(ctx, p, "GetConfig", "GET", "/configs/"+name, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -429,19 +710,24 @@ func (p *LogProject) GetConfig(name string) (c *LogConfig, err error) {
 
 // UpdateConfig updates a config.
 func (p *LogProject) UpdateConfig(c *LogConfig) (err error) {
+	return p.UpdateConfigWithContext(context.Background(), c)
+}
+
+// UpdateConfigWithContext is like UpdateConfig but uses ctx to cancel the request.
+func (p *LogProject) UpdateConfigWithContext(ctx context.Context, c *LogConfig) (err error) {
 	body, err := json.Marshal(c)
 	if err != nil {
-		return NewClientError(err.Error())
+		return p.opError(err, "UpdateConfig", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+		"Accept-Encoding":   DefaultCompressType,
 	}
-	r, err := request(p, "PUT", "/configs/"+c.Name, h, body)
+	r, err := request(ctx, p, "UpdateConfig", "PUT", "/configs/"+c.Name, h, body)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ = ioutil.ReadAll(r.Body)
@@ -455,19 +741,24 @@ func (p *LogProject) UpdateConfig(c *LogConfig) (err error) {
 
 // CreateConfig creates a new config in SLS.
 func (p *LogProject) CreateConfig(c *LogConfig) (err error) {
+	return p.CreateConfigWithContext(context.Background(), c)
+}
+
+// CreateConfigWithContext is like CreateConfig but uses ctx to cancel the request.
+func (p *LogProject) CreateConfigWithContext(ctx context.Context, c *LogConfig) (err error) {
 	body, err := json.Marshal(c)
 	if err != nil {
-		return NewClientError(err.Error())
+		return p.opError(err, "CreateConfig", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+		"Accept-Encoding":   DefaultCompressType,
 	}
-	r, err := request(p, "POST", "/configs", h, body)
+	r, err := request(ctx, p, "CreateConfig", "POST", "/configs", h, body)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, err = ioutil.ReadAll(r.Body)
@@ -481,12 +772,17 @@ func (p *LogProject) CreateConfig(c *LogConfig) (err error) {
 
 // DeleteConfig deletes a config according by config name.
 func (p *LogProject) DeleteConfig(name string) (err error) {
+	return p.DeleteConfigWithContext(context.Background(), name)
+}
+
+// DeleteConfigWithContext is like DeleteConfig but uses ctx to cancel the request.
+func (p *LogProject) DeleteConfigWithContext(ctx context.Context, name string) (err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
-	r, err := request(p, "DELETE", "/configs/"+name, h, nil)
+	r, err := request(ctx, p, "DeleteConfig", "DELETE", "/configs/"+name, h, nil)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ := ioutil.ReadAll(r.Body)
@@ -501,13 +797,18 @@ func (p *LogProject) DeleteConfig(name string) (err error) {
 
 // GetAppliedMachineGroups returns applied machine group names list according config name.
 func (p *LogProject) GetAppliedMachineGroups(confName string) (groupNames []string, err error) {
+	return p.GetAppliedMachineGroupsWithContext(context.Background(), confName)
+}
+
+// GetAppliedMachineGroupsWithContext is like GetAppliedMachineGroups but uses ctx to cancel the request.
+func (p *LogProject) GetAppliedMachineGroupsWithContext(ctx context.Context, confName string) (groupNames []string, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 	uri := fmt.Sprintf("/configs/%v/machinegroups", confName)
-	r, err := request(p, "GET", uri, h, nil)
+	r, err := request(ctx, p, "GetAppliedMachineGroups", "GET", uri, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -529,13 +830,18 @@ func (p *LogProject) GetAppliedMachineGroups(confName string) (groupNames []stri
 
 // GetAppliedConfigs returns applied config names list according machine group name groupName.
 func (p *LogProject) GetAppliedConfigs(groupName string) (confNames []string, err error) {
+	return p.GetAppliedConfigsWithContext(context.Background(), groupName)
+}
+
+// GetAppliedConfigsWithContext is like GetAppliedConfigs but uses ctx to cancel the request.
+func (p *LogProject) GetAppliedConfigsWithContext(ctx context.Context, groupName string) (confNames []string, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 	uri := fmt.Sprintf("/machinegroups/%v/configs", groupName)
-	r, err := request(p, "GET", uri, h, nil)
+	r, err := request(ctx, p, "GetAppliedConfigs", "GET", uri, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -557,13 +863,18 @@ func (p *LogProject) GetAppliedConfigs(groupName string) (confNames []string, er
 
 // ApplyConfigToMachineGroup applies config to machine group.
 func (p *LogProject) ApplyConfigToMachineGroup(confName, groupName string) (err error) {
+	return p.ApplyConfigToMachineGroupWithContext(context.Background(), confName, groupName)
+}
+
+// ApplyConfigToMachineGroupWithContext is like ApplyConfigToMachineGroup but uses ctx to cancel the request.
+func (p *LogProject) ApplyConfigToMachineGroupWithContext(ctx context.Context, confName, groupName string) (err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 	uri := fmt.Sprintf("/machinegroups/%v/configs/%v", groupName, confName)
-	r, err := request(p, "PUT", uri, h, nil)
+	r, err := request(ctx, p, "ApplyConfigToMachineGroup", "PUT", uri, h, nil)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -578,13 +889,18 @@ func (p *LogProject) ApplyConfigToMachineGroup(confName, groupName string) (err
 
 // RemoveConfigFromMachineGroup removes config from machine group.
 func (p *LogProject) RemoveConfigFromMachineGroup(confName, groupName string) (err error) {
+	return p.RemoveConfigFromMachineGroupWithContext(context.Background(), confName, groupName)
+}
+
+// RemoveConfigFromMachineGroupWithContext is like RemoveConfigFromMachineGroup but uses ctx to cancel the request.
+func (p *LogProject) RemoveConfigFromMachineGroupWithContext(ctx context.Context, confName, groupName string) (err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 	uri := fmt.Sprintf("/machinegroups/%v/configs/%v", groupName, confName)
-	r, err := request(p, "DELETE", uri, h, nil)
+	r, err := request(ctx, p, "RemoveConfigFromMachineGroup", "DELETE", uri, h, nil)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	buf, _ := ioutil.ReadAll(r.Body)
@@ -1,16 +1,16 @@
 package sls
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"net/http/httputil"
 	"strconv"
+	"strings"
+	"sync"
 
-	lz4 "github.com/cloudflare/golz4"
 	"github.com/gogo/protobuf/proto"
-	"github.com/golang/glog"
 )
 
 // LogStore defines LogStore struct
@@ -22,7 +22,35 @@ type LogStore struct {
 	CreateTime     uint32
 	LastModifyTime uint32
 
-	project *LogProject
+	project      *LogProject
+	compressType string
+}
+
+// WithCompressType sets the compress type of the logs written to and read
+// from logstore s, i.e. the name of a registered Compressor.
+func (s *LogStore) WithCompressType(compressType string) (*LogStore, error) {
+	if GetCompressor(compressType) == nil {
+		return nil, NewClientError(fmt.Sprintf("unknown compress type:%v", compressType))
+	}
+	s.compressType = compressType
+	return s, nil
+}
+
+// compressor returns the compressor of logstore s.
+func (s *LogStore) compressor() Compressor {
+	if c := GetCompressor(s.compressType); c != nil {
+		return c
+	}
+	return GetCompressor(DefaultCompressType)
+}
+
+// acceptEncoding returns the 'Accept-Encoding' header of the requests of
+// logstore s, or "" if their responses shouldn't be compressed.
+func (s *LogStore) acceptEncoding() string {
+	if name := s.compressor().Name(); name != CompressNone {
+		return name
+	}
+	return ""
 }
 
 // Shard defines shard struct
@@ -30,15 +58,21 @@ type Shard struct {
 	ShardID int `json:"shardID"`
 }
 
-// ListShards returns shard id list of this logstore.
+// ListShards returns shard id list of this logstore, including the
+// read-only shards, see ListShardInfos.
 func (s *LogStore) ListShards() (shardIDs []int, err error) {
+	return s.ListShardsWithContext(context.Background())
+}
+
+// ListShardsWithContext is like ListShards but uses ctx to cancel the request.
+func (s *LogStore) ListShardsWithContext(ctx context.Context) (shardIDs []int, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 	uri := fmt.Sprintf("/logstores/%v/shards", s.Name)
-	r, err := request(s.project, "GET", uri, h, nil)
+	r, err := request(ctx, s.project, "ListShards", "GET", uri, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 	buf, _ := ioutil.ReadAll(r.Body)
 	if r.StatusCode != http.StatusOK {
@@ -55,36 +89,191 @@ func (s *LogStore) ListShards() (shardIDs []int, err error) {
 	return shardIDs, nil
 }
 
+// ListShardInfos returns the shards of this logstore with their status and
+// hash key ranges, see ShardOfHashKey.
+func (s *LogStore) ListShardInfos() (shards []*ShardInfo, err error) {
+	return s.ListShardInfosWithContext(context.Background())
+}
+
+// ListShardInfosWithContext is like ListShardInfos but uses ctx to cancel the request.
+func (s *LogStore) ListShardInfosWithContext(ctx context.Context) (shards []*ShardInfo, err error) {
+	h := map[string]string{
+		"x-log-bodyrawsize": "0",
+	}
+	uri := fmt.Sprintf("/logstores/%v/shards", s.Name)
+	r, err := request(ctx, s.project, "ListShardInfos", "GET", uri, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, s.project.opError(err, "ListShardInfos", r)
+	}
+	if err = json.Unmarshal(buf, &shards); err != nil {
+		return nil, s.project.opError(err, "ListShardInfos", r)
+	}
+	return shards, nil
+}
+
+// SplitShard splits the read-write shard shardID into two shards at hash key
+// midHash: the first one holds the keys before midHash, the second one the
+// others. It returns the shards involved, the split shard is now read-only.
+func (s *LogStore) SplitShard(shardID int, midHash string) (shards []*ShardInfo, err error) {
+	return s.SplitShardWithContext(context.Background(), shardID, midHash)
+}
+
+// SplitShardWithContext is like SplitShard but uses ctx to cancel the request.
+func (s *LogStore) SplitShardWithContext(ctx context.Context, shardID int, midHash string) (shards []*ShardInfo, err error) {
+	if !isHashKey(midHash) {
+		return nil, s.project.opError(NewClientError(fmt.Sprintf("invalid hash key:%v", midHash)), "SplitShard", nil)
+	}
+	uri := fmt.Sprintf("/logstores/%v/shards/%v?action=split&key=%v", s.Name, shardID, strings.ToLower(midHash))
+	return s.manageShards(ctx, "SplitShard", uri)
+}
+
+// MergeShards merges the read-write shard shardID with the read-write shard
+// holding the next hash keys into a new shard. It returns the shards
+// involved, the merged shards are now read-only.
+func (s *LogStore) MergeShards(shardID int) (shards []*ShardInfo, err error) {
+	return s.MergeShardsWithContext(context.Background(), shardID)
+}
+
+// MergeShardsWithContext is like MergeShards but uses ctx to cancel the request.
+func (s *LogStore) MergeShardsWithContext(ctx context.Context, shardID int) (shards []*ShardInfo, err error) {
+	uri := fmt.Sprintf("/logstores/%v/shards/%v?action=merge", s.Name, shardID)
+	return s.manageShards(ctx, "MergeShards", uri)
+}
+
+// manageShards posts a split or merge action to uri and returns the shards
+// of the response.
+func (s *LogStore) manageShards(ctx context.Context, op, uri string) (shards []*ShardInfo, err error) {
+	h := map[string]string{
+		"x-log-bodyrawsize": "0",
+	}
+	r, err := request(ctx, s.project, op, "POST", uri, h, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, s.project.opError(err, op, r)
+	}
+	if err = json.Unmarshal(buf, &shards); err != nil {
+		return nil, s.project.opError(err, op, r)
+	}
+	return shards, nil
+}
+
 // PutLogs put logs into logstore.
 // The callers should transform user logs into LogGroup.
+// A log group over MaxLogGroupSize bytes or MaxLogGroupCount logs is
+// rejected without a request, see PutLogsChunked.
 func (s *LogStore) PutLogs(lg *LogGroup) (err error) {
+	return s.PutLogsWithContext(context.Background(), lg)
+}
+
+// PutLogsWithContext is like PutLogs but uses ctx to cancel the request.
+func (s *LogStore) PutLogsWithContext(ctx context.Context, lg *LogGroup) (err error) {
+	uri := fmt.Sprintf("/logstores/%v", s.Name)
+	return s.putLogs(ctx, "PutLogs", uri, lg)
+}
+
+// PutLogsWithHashKey puts logs into the shard whose hash key range holds
+// hashKey, a 128 bits key in hex like the keys returned by HashKey. The logs
+// put with the same hash key stay in order, as long as the shards aren't
+// split or merged.
+func (s *LogStore) PutLogsWithHashKey(hashKey string, lg *LogGroup) (err error) {
+	return s.PutLogsWithHashKeyWithContext(context.Background(), hashKey, lg)
+}
+
+// PutLogsWithHashKeyWithContext is like PutLogsWithHashKey but uses ctx to cancel the request.
+func (s *LogStore) PutLogsWithHashKeyWithContext(ctx context.Context, hashKey string, lg *LogGroup) (err error) {
+	if !isHashKey(hashKey) {
+		return s.project.opError(NewClientError(fmt.Sprintf("invalid hash key:%v", hashKey)), "PutLogsWithHashKey", nil)
+	}
+	uri := fmt.Sprintf("/logstores/%v/shards/route?key=%v", s.Name, strings.ToLower(hashKey))
+	return s.putLogs(ctx, "PutLogsWithHashKey", uri, lg)
+}
+
+// PutLogsChunked is like PutLogs for log groups of any size: it splits lg
+// with SplitLogGroup into chunks within the limits of PutLogs, and puts up
+// to maxInFlight chunks concurrently, one at a time if maxInFlight <= 1.
+// The result tells which chunks failed, err is the error of the first one.
+func (s *LogStore) PutLogsChunked(lg *LogGroup, maxInFlight int) (result *PutLogsResult, err error) {
+	return s.PutLogsChunkedWithContext(context.Background(), lg, maxInFlight)
+}
+
+// PutLogsChunkedWithContext is like PutLogsChunked but uses ctx to cancel the requests.
+func (s *LogStore) PutLogsChunkedWithContext(ctx context.Context, lg *LogGroup, maxInFlight int) (result *PutLogsResult, err error) {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	uri := fmt.Sprintf("/logstores/%v", s.Name)
+	chunks := SplitLogGroup(lg, MaxLogGroupSize, MaxLogGroupCount)
+	result = &PutLogsResult{Chunks: make([]*ChunkResult, len(chunks))}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxInFlight)
+	offset := 0
+	for i, chunk := range chunks {
+		r := &ChunkResult{Offset: offset, Count: len(chunk.Logs), Size: chunk.Size()}
+		result.Chunks[i] = r
+		offset += r.Count
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(chunk *LogGroup) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r.Err = s.putLogs(ctx, "PutLogsChunked", uri, chunk)
+		}(chunk)
+	}
+	wg.Wait()
+
+	if failed := result.Failed(); len(failed) > 0 {
+		return result, failed[0].Err
+	}
+	return result, nil
+}
+
+// putLogs posts log group lg to uri, for operation op.
+func (s *LogStore) putLogs(ctx context.Context, op, uri string, lg *LogGroup) (err error) {
 	if len(lg.Logs) == 0 {
 		// empty log group
 		return nil
 	}
+	if err := checkLogGroup(lg, lg.Size()); err != nil {
+		return s.project.opError(err, op, nil)
+	}
 
 	body, err := proto.Marshal(lg)
 	if err != nil {
-		return NewClientError(err.Error())
+		return s.project.opError(err, op, nil)
 	}
 
-	// Compresse body with lz4
-	out := make([]byte, lz4.CompressBound(body))
-	n, err := lz4.Compress(body, out)
+	// Compress body with the compressor of the logstore
+	c := s.compressor()
+	out, err := c.Compress(body)
 	if err != nil {
-		return NewClientError(err.Error())
+		return s.project.opError(err, op, nil)
 	}
 
 	h := map[string]string{
-		"x-log-compresstype": "lz4",
-		"x-log-bodyrawsize":  fmt.Sprintf("%v", len(body)),
-		"Content-Type":       "application/x-protobuf",
+		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
+		"Content-Type":      "application/x-protobuf",
+	}
+	if c.Name() != CompressNone {
+		h["x-log-compresstype"] = c.Name()
 	}
 
-	uri := fmt.Sprintf("/logstores/%v", s.Name)
-	r, err := request(s.project, "POST", uri, h, out[:n])
+	// PutLogs isn't idempotent: the server may have stored a log group
+	// whose request failed, so only the requests rejected before being
+	// processed, e.g. ServerBusy, or never sent are retried.
+	r, err := request(ctx, s.project, op, "POST", uri, h, out)
 	if err != nil {
-		return NewClientError(err.Error())
+		return clientError(err)
 	}
 
 	body, _ = ioutil.ReadAll(r.Body)
@@ -100,33 +289,24 @@ func (s *LogStore) PutLogs(lg *LogGroup) (err error) {
 // The from can be in three form: a) unix timestamp in seccond, b) "begin", c) "end".
 // For more detail please read: http://gitlab.alibaba-inc.com/sls/doc/blob/master/api/shard.md#logstore
 func (s *LogStore) GetCursor(shardID int, from string) (cursor string, err error) {
+	return s.GetCursorWithContext(context.Background(), shardID, from)
+}
+
+// GetCursorWithContext is like GetCursor but uses ctx to cancel the request.
+func (s *LogStore) GetCursorWithContext(ctx context.Context, shardID int, from string) (cursor string, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 	uri := fmt.Sprintf("/logstores/%v/shards/%v?type=cursor&from=%v",
 		s.Name, shardID, from)
-	r, err := request(s.project, "GET", uri, h, nil)
+	r, err := request(ctx, s.project, "GetCursor", "GET", uri, h, nil)
 	if err != nil {
 		return
 	}
 
 	buf, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return
-	}
-
-	if r.StatusCode != http.StatusOK {
-		errMsg := &Error{}
-		err = json.Unmarshal(buf, errMsg)
-		if err != nil {
-			err = fmt.Errorf("failed to get cursor")
-			dump, _ := httputil.DumpResponse(r, true)
-			if glog.V(1) {
-				glog.Error(string(dump))
-			}
-			return
-		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		err = s.project.opError(err, "GetCursor", r)
 		return
 	}
 
@@ -137,6 +317,7 @@ func (s *LogStore) GetCursor(shardID int, from string) (cursor string, err error
 
 	err = json.Unmarshal(buf, body)
 	if err != nil {
+		err = s.project.opError(err, "GetCursor", r)
 		return
 	}
 	cursor = body.Cursor
@@ -148,10 +329,25 @@ func (s *LogStore) GetCursor(shardID int, from string) (cursor string, err error
 // The nextCursor is the next curosr can be used to read logs at next time.
 func (s *LogStore) GetLogsBytes(shardID int, cursor, endCursor string,
 	logGroupMaxCount int) (out []byte, nextCursor string, err error) {
+	return s.GetLogsBytesWithContext(context.Background(), shardID, cursor, endCursor, logGroupMaxCount)
+}
+
+// GetLogsBytesWithContext is like GetLogsBytes but uses ctx to cancel the request.
+func (s *LogStore) GetLogsBytesWithContext(ctx context.Context, shardID int, cursor, endCursor string,
+	logGroupMaxCount int) (out []byte, nextCursor string, err error) {
+	out, nextCursor, _, err = s.getLogsBytes(ctx, shardID, cursor, endCursor, logGroupMaxCount)
+	return
+}
+
+// getLogsBytes is GetLogsBytesWithContext, it returns the response too.
+func (s *LogStore) getLogsBytes(ctx context.Context, shardID int, cursor, endCursor string,
+	logGroupMaxCount int) (out []byte, nextCursor string, r *http.Response, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 		"Accept":            "application/x-protobuf",
-		"Accept-Encoding":   "lz4",
+	}
+	if enc := s.acceptEncoding(); enc != "" {
+		h["Accept-Encoding"] = enc
 	}
 
 	uri := ""
@@ -163,73 +359,36 @@ func (s *LogStore) GetLogsBytes(shardID int, cursor, endCursor string,
 			s.Name, shardID, cursor, endCursor, logGroupMaxCount)
 	}
 
-	r, err := request(s.project, "GET", uri, h, nil)
+	r, err = request(ctx, s.project, "GetLogsBytes", "GET", uri, h, nil)
 	if err != nil {
 		return
 	}
 
 	buf, err := ioutil.ReadAll(r.Body)
 	if err != nil {
+		err = s.project.opError(err, "GetLogsBytes", r)
 		return
 	}
 
-	if r.StatusCode != http.StatusOK {
-		errMsg := &Error{}
-		err = json.Unmarshal(buf, errMsg)
-		if err != nil {
-			err = fmt.Errorf("failed to get cursor")
-			dump, _ := httputil.DumpResponse(r, true)
-			if glog.V(1) {
-				glog.Error(string(dump))
-			}
-			return
-		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
-		return
-	}
-	v, ok := r.Header["X-Log-Compresstype"]
+	// The body is decompressed by request.
+	v, ok := r.Header["X-Log-Cursor"]
 	if !ok || len(v) == 0 {
-		err = fmt.Errorf("can't find 'x-log-compresstype' header")
-		return
-	}
-	if v[0] != "lz4" {
-		err = fmt.Errorf("unexpected compress type:%v", v[0])
-		return
-	}
-
-	v, ok = r.Header["X-Log-Cursor"]
-	if !ok || len(v) == 0 {
-		err = fmt.Errorf("can't find 'x-log-cursor' header")
+		err = s.project.opError(NewClientError("can't find 'x-log-cursor' header"), "GetLogsBytes", r)
 		return
 	}
 	nextCursor = v[0]
-
-	v, ok = r.Header["X-Log-Bodyrawsize"]
-	if !ok || len(v) == 0 {
-		err = fmt.Errorf("can't find 'x-log-bodyrawsize' header")
-		return
-	}
-	bodyRawSize, err := strconv.Atoi(v[0])
-	if err != nil {
-		return
-	}
-
-	out = make([]byte, bodyRawSize)
-	err = lz4.Uncompress(buf, out)
-	if err != nil {
-		return
-	}
-
+	out = buf
 	return
 }
 
-// LogsBytesDecode decodes logs binary data returned by GetLogsBytes API
+// LogsBytesDecode decodes logs binary data returned by GetLogsBytes API.
+// NewLogGroupIterator reads the data without unmarshaling all of it.
 func LogsBytesDecode(data []byte) (gl *LogGroupList, err error) {
 
 	gl = &LogGroupList{}
 	err = proto.Unmarshal(data, gl)
 	if err != nil {
-		return nil, err
+		return nil, clientError(err)
 	}
 
 	return gl, nil
@@ -240,15 +399,21 @@ func LogsBytesDecode(data []byte) (gl *LogGroupList, err error) {
 // The nextCursor is the next cursor can be used to read logs at next time.
 func (s *LogStore) PullLogs(shardID int, cursor, endCursor string,
 	logGroupMaxCount int) (gl *LogGroupList, nextCursor string, err error) {
+	return s.PullLogsWithContext(context.Background(), shardID, cursor, endCursor, logGroupMaxCount)
+}
+
+// PullLogsWithContext is like PullLogs but uses ctx to cancel the request.
+func (s *LogStore) PullLogsWithContext(ctx context.Context, shardID int, cursor, endCursor string,
+	logGroupMaxCount int) (gl *LogGroupList, nextCursor string, err error) {
 
-	out, nextCursor, err := s.GetLogsBytes(shardID, cursor, endCursor, logGroupMaxCount)
+	out, nextCursor, r, err := s.getLogsBytes(ctx, shardID, cursor, endCursor, logGroupMaxCount)
 	if err != nil {
 		return nil, "", err
 	}
 
 	gl, err = LogsBytesDecode(out)
 	if err != nil {
-		return nil, "", err
+		return nil, "", s.project.opError(err, "PullLogs", r)
 	}
 
 	return gl, nextCursor, nil
@@ -256,6 +421,11 @@ func (s *LogStore) PullLogs(shardID int, cursor, endCursor string,
 
 // GetHistograms query logs with [from, to) time range
 func (s *LogStore) GetHistograms(topic string, from int64, to int64, queryExp string) (*GetHistogramsResponse, error) {
+	return s.GetHistogramsWithContext(context.Background(), topic, from, to, queryExp)
+}
+
+// GetHistogramsWithContext is like GetHistograms but uses ctx to cancel the request.
+func (s *LogStore) GetHistogramsWithContext(ctx context.Context, topic string, from int64, to int64, queryExp string) (*GetHistogramsResponse, error) {
 
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
@@ -264,9 +434,9 @@ func (s *LogStore) GetHistograms(topic string, from int64, to int64, queryExp st
 
 	uri := fmt.Sprintf("/logstores/%v?type=histogram&topic=%v&from=%v&to=%v&query=%v", s.Name, topic, from, to, queryExp)
 
-	r, err := request(s.project, "GET", uri, h, nil)
+	r, err := request(ctx, s.project, "GetHistograms", "GET", uri, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 
 	body, _ := ioutil.ReadAll(r.Body)
@@ -279,12 +449,12 @@ func (s *LogStore) GetHistograms(topic string, from int64, to int64, queryExp st
 	histograms := []SingleHistogram{}
 	err = json.Unmarshal(body, &histograms)
 	if err != nil {
-		return nil, err
+		return nil, s.project.opError(err, "GetHistograms", r)
 	}
 
 	count, err := strconv.ParseInt(r.Header[GetLogsCountHeader][0], 10, 32)
 	if err != nil {
-		return nil, err
+		return nil, s.project.opError(err, "GetHistograms", r)
 	}
 	getHistogramsResponse := GetHistogramsResponse{
 		Progress: r.Header[ProgressHeader][0],
@@ -298,6 +468,12 @@ func (s *LogStore) GetHistograms(topic string, from int64, to int64, queryExp st
 // GetLogs query logs with [from, to) time range
 func (s *LogStore) GetLogs(topic string, from int64, to int64, queryExp string,
 	maxLineNum int64, offset int64, reverse bool) (*GetLogsResponse, error) {
+	return s.GetLogsWithContext(context.Background(), topic, from, to, queryExp, maxLineNum, offset, reverse)
+}
+
+// GetLogsWithContext is like GetLogs but uses ctx to cancel the request.
+func (s *LogStore) GetLogsWithContext(ctx context.Context, topic string, from int64, to int64, queryExp string,
+	maxLineNum int64, offset int64, reverse bool) (*GetLogsResponse, error) {
 
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
@@ -306,9 +482,9 @@ func (s *LogStore) GetLogs(topic string, from int64, to int64, queryExp string,
 
 	uri := fmt.Sprintf("/logstores/%v?type=log&topic=%v&from=%v&to=%v&query=%v&line=%v&offset=%v&reverse=%v", s.Name, topic, from, to, queryExp, maxLineNum, offset, reverse)
 
-	r, err := request(s.project, "GET", uri, h, nil)
+	r, err := request(ctx, s.project, "GetLogs", "GET", uri, h, nil)
 	if err != nil {
-		return nil, NewClientError(err.Error())
+		return nil, clientError(err)
 	}
 
 	body, _ := ioutil.ReadAll(r.Body)
@@ -321,12 +497,12 @@ func (s *LogStore) GetLogs(topic string, from int64, to int64, queryExp string,
 	logs := []map[string]string{}
 	err = json.Unmarshal(body, &logs)
 	if err != nil {
-		return nil, err
+		return nil, s.project.opError(err, "GetLogs", r)
 	}
 
 	count, err := strconv.ParseInt(r.Header[GetLogsCountHeader][0], 10, 32)
 	if err != nil {
-		return nil, err
+		return nil, s.project.opError(err, "GetLogs", r)
 	}
 
 	getLogsResponse := GetLogsResponse{
@@ -340,42 +516,61 @@ func (s *LogStore) GetLogs(topic string, from int64, to int64, queryExp string,
 
 // CreateIndex ...
 func (s *LogStore) CreateIndex(index Index) error {
+	return s.CreateIndexWithContext(context.Background(), index)
+}
+
+// CreateIndexWithContext is like CreateIndex but uses ctx to cancel the request.
+func (s *LogStore) CreateIndexWithContext(ctx context.Context, index Index) error {
 	body, err := json.Marshal(index)
 	if err != nil {
-		return err
+		return s.project.opError(err, "CreateIndex", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+	}
+	if enc := s.acceptEncoding(); enc != "" {
+		h["Accept-Encoding"] = enc
 	}
 
 	uri := fmt.Sprintf("/logstores/%s/index", s.Name)
-	_, err = request(s.project, "POST", uri, h, body)
+	_, err = request(ctx, s.project, "CreateIndex", "POST", uri, h, body)
 	return err
 }
 
 // UpdateIndex ...
 func (s *LogStore) UpdateIndex(index Index) error {
+	return s.UpdateIndexWithContext(context.Background(), index)
+}
+
+// UpdateIndexWithContext is like UpdateIndex but uses ctx to cancel the request.
+func (s *LogStore) UpdateIndexWithContext(ctx context.Context, index Index) error {
 	body, err := json.Marshal(index)
 	if err != nil {
-		return err
+		return s.project.opError(err, "UpdateIndex", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+	}
+	if enc := s.acceptEncoding(); enc != "" {
+		h["Accept-Encoding"] = enc
 	}
 
 	uri := fmt.Sprintf("/logstores/%s/index", s.Name)
-	_, err = request(s.project, "PUT", uri, h, body)
+	_, err = request(ctx, s.project, "UpdateIndex", "PUT", uri, h, body)
 	return err
 }
 
 // DeleteIndex ...
 func (s *LogStore) DeleteIndex() error {
+	return s.DeleteIndexWithContext(context.Background())
+}
+
+// DeleteIndexWithContext is like DeleteIndex but uses ctx to cancel the request.
+func (s *LogStore) DeleteIndexWithContext(ctx context.Context) error {
 	type Body struct {
 		project string `json:"projectName"`
 		store   string `json:"logstoreName"`
@@ -386,21 +581,28 @@ func (s *LogStore) DeleteIndex() error {
 		store:   s.Name,
 	})
 	if err != nil {
-		return err
+		return s.project.opError(err, "DeleteIndex", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+	}
+	if enc := s.acceptEncoding(); enc != "" {
+		h["Accept-Encoding"] = enc
 	}
 
 	uri := fmt.Sprintf("/logstores/%s/index", s.Name)
-	_, err = request(s.project, "DELETE", uri, h, body)
+	_, err = request(ctx, s.project, "DeleteIndex", "DELETE", uri, h, body)
 	return err
 }
 
 func (s *LogStore) GetIndex() (*Index, error) {
+	return s.GetIndexWithContext(context.Background())
+}
+
+// GetIndexWithContext is like GetIndex but uses ctx to cancel the request.
+func (s *LogStore) GetIndexWithContext(ctx context.Context) (*Index, error) {
 	type Body struct {
 		project string `json:"projectName"`
 		store   string `json:"logstoreName"`
@@ -411,25 +613,28 @@ func (s *LogStore) GetIndex() (*Index, error) {
 		store:   s.Name,
 	})
 	if err != nil {
-		return nil, err
+		return nil, s.project.opError(err, "GetIndex", nil)
 	}
 
 	h := map[string]string{
 		"x-log-bodyrawsize": fmt.Sprintf("%v", len(body)),
 		"Content-Type":      "application/json",
-		"Accept-Encoding":   "deflate", // TODO: support lz4
+	}
+	if enc := s.acceptEncoding(); enc != "" {
+		h["Accept-Encoding"] = enc
 	}
 
 	uri := fmt.Sprintf("/logstores/%s/index", s.Name)
-	resp, err := request(s.project, "GET", uri, h, body)
+	resp, err := request(ctx, s.project, "GetIndex", "GET", uri, h, body)
 	if err != nil {
+		return nil, err
 	}
 
 	index := &Index{}
 	data, _ := ioutil.ReadAll(resp.Body)
 	err = json.Unmarshal(data, index)
 	if err != nil {
-		return nil, err
+		return nil, s.project.opError(err, "GetIndex", resp)
 	}
 
 	return index, err
@@ -0,0 +1,99 @@
+package sls
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+func TestLogTimeNsWireFormat(t *testing.T) {
+	l := &Log{Time: proto.Uint32(1), TimeNs: proto.Uint32(2)}
+	data, err := l.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{0x08, 0x01, 0x25, 0x02, 0x00, 0x00, 0x00}; !bytes.Equal(data, expected) {
+		t.Errorf("unexpected data %x", data)
+	}
+	if len(data) != l.Size() {
+		t.Errorf("size %v, marshaled %v bytes", l.Size(), len(data))
+	}
+
+	// The logs without a nanosecond part are marshaled like before.
+	data, err = (&Log{Time: proto.Uint32(1)}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x08, 0x01}) {
+		t.Errorf("unexpected data %x", data)
+	}
+	decoded := &Log{}
+	if err := decoded.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TimeNs != nil {
+		t.Errorf("unexpected nanoseconds %v", decoded.GetTimeNs())
+	}
+}
+
+func TestLogGroupTags(t *testing.T) {
+	lg := &LogGroup{
+		Topic: proto.String("topic"),
+		Logs:  []*Log{{Time: proto.Uint32(1500000000), TimeNs: proto.Uint32(123)}},
+	}
+	lg.SetTag(TagHostname, "host-1")
+	lg.SetTag(TagPackID, "pack-1")
+	lg.SetTag(TagHostname, "host-2")
+	lg.SetTag("dropped", "x")
+	lg.DeleteTag("dropped")
+
+	data, err := lg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != lg.Size() {
+		t.Errorf("size %v, marshaled %v bytes", lg.Size(), len(data))
+	}
+	decoded := &LogGroup{}
+	if err := decoded.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lg, decoded) {
+		t.Errorf("unexpected log group %v", decoded)
+	}
+	if !reflect.DeepEqual(decoded.Tags(), map[string]string{TagHostname: "host-2", TagPackID: "pack-1"}) {
+		t.Errorf("unexpected tags %v", decoded.Tags())
+	}
+	if v, ok := decoded.Tag(TagHostname); !ok || v != "host-2" {
+		t.Errorf("unexpected tag %v", v)
+	}
+	if _, ok := decoded.Tag("dropped"); ok {
+		t.Error("tag isn't deleted")
+	}
+
+	var rows []struct {
+		Host string `sls:"__tag__:__hostname__"`
+	}
+	if err := DecodeLogGroup(decoded, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Host != "host-2" {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+}
+
+func TestLogTimestamp(t *testing.T) {
+	l := &Log{}
+	at := time.Unix(1500000000, 123456789)
+	l.SetTimestamp(at)
+	if l.GetTime() != 1500000000 || l.GetTimeNs() != 123456789 || !l.Timestamp().Equal(at) {
+		t.Errorf("unexpected time %v %v", l.GetTime(), l.GetTimeNs())
+	}
+	l.SetTimestamp(time.Unix(1500000001, 0))
+	if l.GetTime() != 1500000001 || l.TimeNs != nil {
+		t.Errorf("unexpected time %v %v", l.GetTime(), l.GetTimeNs())
+	}
+}
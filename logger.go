@@ -0,0 +1,85 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// LogLevel is the level of a log of the SDK. The levels have the values of
+// the levels of log/slog.
+type LogLevel int
+
+// Levels of the logs of the SDK.
+const (
+	LevelDebug LogLevel = -4 // The attempts of the requests and their responses
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4 // The failed attempts retried
+	LevelError LogLevel = 8
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Field is a structured field of a log, e.g. the operation of a request.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger receives the logs of the SDK, see NewSlogLogger. The headers in
+// the logs have their credentials redacted. Implementations must be safe
+// for concurrent use.
+type Logger interface {
+	// Enabled tells whether the logs of level are logged, so that the SDK
+	// doesn't format the others.
+	Enabled(ctx context.Context, level LogLevel) bool
+	Log(ctx context.Context, level LogLevel, msg string, fields ...Field)
+}
+
+// LoggerFunc adapts a function to a Logger logging all the levels.
+type LoggerFunc func(ctx context.Context, level LogLevel, msg string, fields ...Field)
+
+// Enabled returns true.
+func (f LoggerFunc) Enabled(ctx context.Context, level LogLevel) bool {
+	return true
+}
+
+// Log calls f.
+func (f LoggerFunc) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {
+	f(ctx, level, msg, fields...)
+}
+
+// logEnabled tells whether project p logs the logs of level.
+func (p *LogProject) logEnabled(ctx context.Context, level LogLevel) bool {
+	return p.Logger != nil && p.Logger.Enabled(ctx, level)
+}
+
+// redacted replaces the values of the headers holding credentials in logs.
+const redacted = "REDACTED"
+
+// redactHeaders returns the headers to log, without credentials.
+func redactHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k, vals := range h {
+		switch strings.ToLower(k) {
+		case "authorization", "x-acs-security-token":
+			headers[k] = redacted
+		default:
+			headers[k] = strings.Join(vals, ",")
+		}
+	}
+	return headers
+}
@@ -0,0 +1,32 @@
+//go:build go1.21
+// +build go1.21
+
+package sls
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slogLogger is a Logger writing to a *slog.Logger.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing the logs of the SDK to logger,
+// with their fields as attributes.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) Enabled(ctx context.Context, level LogLevel) bool {
+	return l.logger.Enabled(ctx, slog.Level(level))
+}
+
+func (l slogLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	l.logger.LogAttrs(ctx, slog.Level(level), msg, attrs...)
+}
@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package sls
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	if logger.Enabled(context.Background(), LevelDebug) || !logger.Enabled(context.Background(), LevelWarn) {
+		t.Error("unexpected enabled levels")
+	}
+	logger.Log(context.Background(), LevelWarn, "sls: retry request", Field{"operation", "PutLogs"}, Field{"attempt", 2})
+	if s := buf.String(); !strings.Contains(s, `level=WARN msg="sls: retry request" operation=PutLogs attempt=2`) {
+		t.Errorf("unexpected log: %v", s)
+	}
+}
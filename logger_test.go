@@ -0,0 +1,89 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type logRecord struct {
+	level  LogLevel
+	msg    string
+	fields map[string]interface{}
+}
+
+// recordLogger records the logs of levels from min.
+type recordLogger struct {
+	min     LogLevel
+	mu      sync.Mutex
+	records []logRecord
+}
+
+func (l *recordLogger) Enabled(ctx context.Context, level LogLevel) bool {
+	return level >= l.min
+}
+
+func (l *recordLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {
+	r := logRecord{level: level, msg: msg, fields: make(map[string]interface{})}
+	for _, f := range fields {
+		r.fields[f.Key] = f.Value
+	}
+	l.mu.Lock()
+	l.records = append(l.records, r)
+	l.mu.Unlock()
+}
+
+func TestLoggerRequests(t *testing.T) {
+	rt := &scriptTransport{script: []*Error{
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+	}}
+	p := newRetryTestProject(rt)
+	p.WithToken("mockSecurityToken")
+	logger := &recordLogger{min: LevelDebug}
+	p.WithLogger(logger)
+
+	if _, err := p.ListLogStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+	for _, r := range logger.records {
+		msgs = append(msgs, r.level.String()+" "+r.msg)
+		if headers, ok := r.fields["headers"].(map[string]string); ok && r.msg == "sls: send request" {
+			if headers["Authorization"] != redacted || headers["X-Acs-Security-Token"] != redacted {
+				t.Errorf("credentials aren't redacted: %v", headers)
+			}
+			if headers["X-Log-Apiversion"] != version {
+				t.Errorf("unexpected headers: %v", headers)
+			}
+		}
+	}
+	expected := []string{
+		"DEBUG sls: send request",
+		"DEBUG sls: receive response",
+		"WARN sls: retry request",
+		"DEBUG sls: send request",
+		"DEBUG sls: receive response",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected logs:\n%v", strings.Join(msgs, "\n"))
+	}
+	retry := logger.records[2].fields
+	if retry["operation"] != "ListLogStore" || retry["attempt"] != 1 {
+		t.Errorf("unexpected fields: %v", retry)
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	p := newRetryTestProject(&scriptTransport{})
+	logger := &recordLogger{min: LevelWarn}
+	p.WithLogger(logger)
+	if _, err := p.ListLogStore(); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.records) != 0 {
+		t.Errorf("unexpected logs: %v", logger.records)
+	}
+}
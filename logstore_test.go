@@ -1,52 +1,38 @@
-package sls
+package sls_test
 
 import (
-	"os"
-	"fmt"
 	"testing"
 	"time"
 
+	sls "github.com/galaxydi/go-loghub"
+	"github.com/galaxydi/go-loghub/slstest"
 	"github.com/gogo/protobuf/proto"
-	"github.com/golang/glog"
 	"github.com/stretchr/testify/suite"
 )
 
 func TestLogStore(t *testing.T) {
 	suite.Run(t, new(LogstoreTestSuite))
-	glog.Flush()
 }
 
 type LogstoreTestSuite struct {
 	suite.Suite
-	endpoint        string
-	projectName     string
-	logstoreName    string
-	accessKeyID     string
-	accessKeySecret string
-	Project         *LogProject
-	Logstore        *LogStore
+	server   *slstest.Server
+	Project  *sls.LogProject
+	Logstore *sls.LogStore
 }
 
 func (s *LogstoreTestSuite) SetupTest() {
-	s.endpoint = os.Getenv("LOG_TEST_ENDPOINT")
-	s.projectName = os.Getenv("LOG_TEST_PROJECT")
-	s.logstoreName = os.Getenv("LOG_TEST_LOGSTORE")
-	s.accessKeyID = os.Getenv("LOG_TEST_ACCESS_KEY_ID")
-	s.accessKeySecret = os.Getenv("LOG_TEST_ACCESS_KEY_SECRET")
-	slsProject, err := NewLogProject(s.projectName, s.endpoint, s.accessKeyID, s.accessKeySecret)
-	s.Nil(err)
-	s.NotNil(slsProject)
-	s.Project = slsProject
-	slsLogstore, err := s.Project.GetLogStore(s.logstoreName)
-	s.Nil(err)
-	s.NotNil(slsLogstore)
-	s.Logstore = slsLogstore
+	s.server, s.Logstore = slstest.NewLogStore(s.T(), 1)
+	s.Project = s.server.NewProject(slstest.ProjectName)
 }
 
 func (s *LogstoreTestSuite) TestCheckLogstoreExist() {
 	exist, err := s.Project.CheckLogstoreExist("not-exist-logstore")
 	s.Nil(err)
 	s.False(exist)
+	exist, err = s.Project.CheckLogstoreExist(slstest.LogStoreName)
+	s.Nil(err)
+	s.True(exist)
 }
 
 func (s *LogstoreTestSuite) TestCheckMachineGroupExist() {
@@ -62,53 +48,54 @@ func (s *LogstoreTestSuite) TestCheckConfigExist() {
 }
 
 func (s *LogstoreTestSuite) TestPutLogs() {
-	content := &LogContent{
+	content := &sls.LogContent{
 		Key:   proto.String("demo_key"),
 		Value: proto.String("demo_value"),
 	}
-	logRecord := &Log{
+	logRecord := &sls.Log{
 		Time:     proto.Uint32(uint32(time.Now().Unix())),
-		Contents: []*LogContent{content},
+		Contents: []*sls.LogContent{content},
 	}
-	lg := &LogGroup{
+	lg := &sls.LogGroup{
 		Topic:  proto.String("test"),
 		Source: proto.String("10.168.122.110"),
-		Logs:   []*Log{logRecord},
+		Logs:   []*sls.Log{logRecord},
 	}
 	err := s.Logstore.PutLogs(lg)
 	s.Nil(err)
 }
 
 func (s *LogstoreTestSuite) TestEmptyLogGroup() {
-	lg := &LogGroup{
+	lg := &sls.LogGroup{
 		Topic:  proto.String("test"),
 		Source: proto.String("10.168.122.110"),
-		Logs:   []*Log{},
+		Logs:   []*sls.Log{},
 	}
 	err := s.Logstore.PutLogs(lg)
 	s.Nil(err)
 }
 
 func (s *LogstoreTestSuite) TestPullLogs() {
-	c := &LogContent{
+	c := &sls.LogContent{
 		Key:   proto.String("error code"),
 		Value: proto.String("InternalServerError"),
 	}
-	l := &Log{
+	l := &sls.Log{
 		Time: proto.Uint32(uint32(time.Now().Unix())),
-		Contents: []*LogContent{
+		Contents: []*sls.LogContent{
 			c,
 		},
 	}
-	lg := &LogGroup{
+	lg := &sls.LogGroup{
 		Topic:  proto.String("demo topic"),
 		Source: proto.String("10.230.201.117"),
-		Logs: []*Log{
+		Logs: []*sls.Log{
 			l,
 		},
 	}
 
 	shards, err := s.Logstore.ListShards()
+	s.Nil(err)
 	s.True(len(shards) > 0)
 
 	err = s.Logstore.PutLogs(lg)
@@ -119,48 +106,48 @@ func (s *LogstoreTestSuite) TestPullLogs() {
 	endCursor, err := s.Logstore.GetCursor(0, "end")
 	s.Nil(err)
 
-	_, _, err = s.Logstore.PullLogs(0, cursor, "", 10)
+	gl, _, err := s.Logstore.PullLogs(0, cursor, "", 10)
 	s.Nil(err)
+	s.Equal(1, len(gl.LogGroups))
 
-	_, _, err = s.Logstore.PullLogs(0, cursor, endCursor, 10)
+	gl, _, err = s.Logstore.PullLogs(0, cursor, endCursor, 10)
 	s.Nil(err)
+	s.Equal(1, len(gl.LogGroups))
 }
 
 func (s *LogstoreTestSuite) TestGetLogs() {
-	idx, err := s.Logstore.GetIndex()
-	if err != nil {
-		fmt.Printf("GetIndex fail, err: %v, idx: %v\n", err, idx)
-		return
+	_, err := s.Logstore.GetIndex()
+	s.NotNil(err)
+	idxConf := sls.Index{
+		TTL:  7,
+		Keys: map[string]sls.IndexKey{},
+		Line: &sls.IndexLine{
+			Token:         []string{",", ":", " "},
+			CaseSensitive: false,
+			IncludeKeys:   []string{},
+			ExcludeKeys:   []string{},
+		},
 	}
-	fmt.Printf("GetIndex success, idx: %v\n", idx)
-	idxConf := Index {
-			TTL: 7,
-			Keys: map[string]IndexKey {
-			},
-			Line: &IndexLine {
-				Token: []string{",", ":", " "},
-				CaseSensitive: false,
-				IncludeKeys: []string{},
-				ExcludeKeys: []string{},
-			},
-		}
-	s.Logstore.CreateIndex(idxConf)
-	time.Sleep(1 * 1000 * time.Millisecond)
-	begin_time := uint32(time.Now().Unix())
-	c := &LogContent{
+	s.Nil(s.Logstore.CreateIndex(idxConf))
+	idx, err := s.Logstore.GetIndex()
+	s.Nil(err)
+	s.Equal(7, idx.TTL)
+
+	beginTime := uint32(time.Now().Unix())
+	c := &sls.LogContent{
 		Key:   proto.String("error code"),
 		Value: proto.String("InternalServerError"),
 	}
-	l := &Log{
-		Time: proto.Uint32(uint32(time.Now().Unix())),
-		Contents: []*LogContent{
+	l := &sls.Log{
+		Time: proto.Uint32(beginTime),
+		Contents: []*sls.LogContent{
 			c,
 		},
 	}
-	lg := &LogGroup{
+	lg := &sls.LogGroup{
 		Topic:  proto.String("demo topic"),
 		Source: proto.String("10.230.201.117"),
-		Logs: []*Log{
+		Logs: []*sls.Log{
 			l,
 		},
 	}
@@ -168,12 +155,10 @@ func (s *LogstoreTestSuite) TestGetLogs() {
 	putErr := s.Logstore.PutLogs(lg)
 	s.Nil(putErr)
 
-	time.Sleep(5 * 1000 * time.Millisecond)
-
-	hResp, hErr := s.Logstore.GetHistograms("", int64(begin_time), int64(begin_time + 2), "InternalServerError")
+	hResp, hErr := s.Logstore.GetHistograms("", int64(beginTime), int64(beginTime+2), "InternalServerError")
 	s.Nil(hErr)
 	s.Equal(hResp.Count, int64(1))
-	lResp, lErr := s.Logstore.GetLogs("", int64(begin_time), int64(begin_time + 2), "InternalServerError", 100, 0, false)
+	lResp, lErr := s.Logstore.GetLogs("", int64(beginTime), int64(beginTime+2), "InternalServerError", 100, 0, false)
 	s.Nil(lErr)
 	s.Equal(lResp.Count, int64(1))
 }
@@ -181,24 +166,25 @@ func (s *LogstoreTestSuite) TestGetLogs() {
 func (s *LogstoreTestSuite) TestLogstore() {
 	logstoreName := "github-test"
 	err := s.Project.DeleteLogStore(logstoreName)
-	time.Sleep(5 * 1000 * time.Millisecond)
-	err = s.Project.CreateLogStore(logstoreName, 14, 2) 
+	s.NotNil(err)
+	err = s.Project.CreateLogStore(logstoreName, 14, 2)
 	s.Nil(err)
-	time.Sleep(10 * 1000 * time.Millisecond)
 	err = s.Project.UpdateLogStore(logstoreName, 7, 2)
 	s.Nil(err)
-	time.Sleep(1 * 1000 * time.Millisecond)
 	logstores, err := s.Project.ListLogStore()
 	s.Nil(err)
-	s.True(len(logstores) >= 1)
+	s.Equal(2, len(logstores))
 	configs, configCount, err := s.Project.ListConfig(0, 100)
 	s.Nil(err)
-	s.True(len(configs) >= 0)
+	s.Equal(0, len(configs))
 	s.Equal(len(configs), configCount)
 	machineGroups, machineGroupCount, err := s.Project.ListMachineGroup(0, 100)
 	s.Nil(err)
-	s.True(len(machineGroups) >= 0)
+	s.Equal(0, len(machineGroups))
 	s.Equal(len(machineGroups), machineGroupCount)
 	err = s.Project.DeleteLogStore(logstoreName)
 	s.Nil(err)
+	exist, err := s.Project.CheckLogstoreExist(logstoreName)
+	s.Nil(err)
+	s.False(exist)
 }
@@ -0,0 +1,203 @@
+package sls
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// This is a pure-Go implementation of the lz4 block format, the format of
+// the bodies with compress type lz4, see
+// https://github.com/lz4/lz4/blob/dev/doc/lz4_Block_format.md.
+
+const (
+	lz4MinMatch     = 4
+	lz4MFLimit      = 12 // The last match starts at least 12 bytes before the end of the block
+	lz4LastLiterals = 5  // The last 5 bytes of the block are literals
+	lz4MaxOffset    = 65535
+	lz4HashLog      = 16
+)
+
+var (
+	errLZ4ShortBuffer = errors.New("lz4: output buffer too small")
+	errLZ4Corrupt     = errors.New("lz4: corrupt input")
+)
+
+// lz4CompressBound returns the max size of n bytes compressed by lz4Compress.
+func lz4CompressBound(n int) int {
+	return n + n/255 + 16
+}
+
+func lz4Hash(v uint32) uint32 {
+	return (v * 2654435761) >> (32 - lz4HashLog)
+}
+
+// lz4Compress compresses src into dst, which must be at least
+// lz4CompressBound(len(src)) long, and returns the compressed size.
+func lz4Compress(src, dst []byte) (int, error) {
+	if len(dst) < lz4CompressBound(len(src)) {
+		return 0, errLZ4ShortBuffer
+	}
+
+	// Positions + 1 of the last sequences of 4 bytes, by hash.
+	table := make([]int32, 1<<lz4HashLog)
+	anchor, di := 0, 0
+	limit := len(src) - lz4MFLimit
+	for i := 0; i < limit; {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := lz4Hash(seq)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			// Skip faster in incompressible data.
+			i += 1 + (i-anchor)>>6
+			continue
+		}
+
+		for i > anchor && ref > 0 && src[i-1] == src[ref-1] {
+			i--
+			ref--
+		}
+		end := i + lz4MinMatch
+		for end < len(src)-lz4LastLiterals && src[end] == src[ref+end-i] {
+			end++
+		}
+
+		di = lz4EmitSequence(dst, di, src[anchor:i], i-ref, end-i)
+		i, anchor = end, end
+	}
+
+	// The last literals.
+	litLen := len(src) - anchor
+	if litLen >= 15 {
+		dst[di] = 15 << 4
+		di = lz4EmitLength(dst, di+1, litLen-15)
+	} else {
+		dst[di] = byte(litLen << 4)
+		di++
+	}
+	di += copy(dst[di:], src[anchor:])
+	return di, nil
+}
+
+// lz4EmitSequence writes literals followed by a match of matchLen bytes at
+// offset into dst at di, and returns the new position in dst.
+func lz4EmitSequence(dst []byte, di int, literals []byte, offset, matchLen int) int {
+	token := di
+	di++
+
+	litLen := len(literals)
+	if litLen >= 15 {
+		dst[token] = 15 << 4
+		di = lz4EmitLength(dst, di, litLen-15)
+	} else {
+		dst[token] = byte(litLen << 4)
+	}
+	di += copy(dst[di:], literals)
+
+	binary.LittleEndian.PutUint16(dst[di:], uint16(offset))
+	di += 2
+
+	matchLen -= lz4MinMatch
+	if matchLen >= 15 {
+		dst[token] |= 15
+		di = lz4EmitLength(dst, di, matchLen-15)
+	} else {
+		dst[token] |= byte(matchLen)
+	}
+	return di
+}
+
+// lz4EmitLength writes the extra bytes of a literal or match length.
+func lz4EmitLength(dst []byte, di, n int) int {
+	for n >= 255 {
+		dst[di] = 255
+		di++
+		n -= 255
+	}
+	dst[di] = byte(n)
+	return di + 1
+}
+
+// lz4Uncompress decompresses src into dst, which must be exactly as long
+// as the uncompressed data.
+func lz4Uncompress(src, dst []byte) error {
+	si, di := 0, 0
+	for {
+		if si >= len(src) {
+			return errLZ4Corrupt
+		}
+		token := src[si]
+		si++
+
+		litLen := int(token >> 4)
+		if litLen == 15 {
+			n, next, err := lz4ReadLength(src, si)
+			if err != nil {
+				return err
+			}
+			litLen += n
+			si = next
+		}
+		if litLen > len(src)-si || litLen > len(dst)-di {
+			return errLZ4Corrupt
+		}
+		di += copy(dst[di:], src[si:si+litLen])
+		si += litLen
+		if si == len(src) {
+			break
+		}
+
+		if len(src)-si < 2 {
+			return errLZ4Corrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[si:]))
+		si += 2
+		if offset == 0 || offset > di {
+			return errLZ4Corrupt
+		}
+
+		matchLen := int(token & 15)
+		if matchLen == 15 {
+			n, next, err := lz4ReadLength(src, si)
+			if err != nil {
+				return err
+			}
+			matchLen += n
+			si = next
+		}
+		matchLen += lz4MinMatch
+		if matchLen > len(dst)-di {
+			return errLZ4Corrupt
+		}
+
+		if offset >= matchLen {
+			di += copy(dst[di:di+matchLen], dst[di-offset:])
+		} else {
+			// Overlapping match, e.g. a run of the same byte.
+			for k := 0; k < matchLen; k++ {
+				dst[di+k] = dst[di-offset+k]
+			}
+			di += matchLen
+		}
+	}
+
+	if di != len(dst) {
+		return errLZ4Corrupt
+	}
+	return nil
+}
+
+// lz4ReadLength reads the extra bytes of a literal or match length.
+func lz4ReadLength(src []byte, si int) (n, next int, err error) {
+	for {
+		if si >= len(src) {
+			return 0, 0, errLZ4Corrupt
+		}
+		b := src[si]
+		si++
+		n += int(b)
+		if b != 255 {
+			return n, si, nil
+		}
+	}
+}
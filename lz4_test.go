@@ -0,0 +1,103 @@
+package sls
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+)
+
+// Blocks compressed by the reference lz4 implementation.
+var lz4Golden = []struct {
+	raw   string
+	block string // hex
+}{
+	{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "1f6101000f506161616161"},
+	{"hello hello hello hello hello world!", "6f68656c6c6f2006000560776f726c6421"},
+	{`{"key":"value","index":1}{"key":"value","index":1}{"key":"value","index":1}{"key":"value","index":1}`,
+		"ff0a7b226b6579223a2276616c7565222c22696e646578223a317d1900335078223a317d"},
+}
+
+func TestLZ4UncompressGolden(t *testing.T) {
+	for _, g := range lz4Golden {
+		block, _ := hex.DecodeString(g.block)
+		out := make([]byte, len(g.raw))
+		if err := lz4Uncompress(block, out); err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != g.raw {
+			t.Errorf("Bad data:%s, expected:%s", out, g.raw)
+		}
+	}
+}
+
+func TestLZ4RoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var inputs [][]byte
+	for _, n := range []int{0, 1, 5, 12, 13, 16, 100, 1000, 70000, 300000} {
+		random := make([]byte, n)
+		rnd.Read(random)
+		text := make([]byte, n)
+		for i := range text {
+			text[i] = "abcdefgh "[rnd.Intn(9)]
+		}
+		inputs = append(inputs, random, text, bytes.Repeat([]byte{'x'}, n))
+	}
+
+	for _, raw := range inputs {
+		block := make([]byte, lz4CompressBound(len(raw)))
+		n, err := lz4Compress(raw, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(raw))
+		if err = lz4Uncompress(block[:n], out); err != nil {
+			t.Fatalf("size %v: %v", len(raw), err)
+		}
+		if !bytes.Equal(out, raw) {
+			t.Errorf("size %v: decompressed data doesn't match", len(raw))
+		}
+	}
+}
+
+func TestLZ4UncompressCorrupt(t *testing.T) {
+	raw := bytes.Repeat([]byte("corrupt lz4 blocks must fail without panic "), 10)
+	block := make([]byte, lz4CompressBound(len(raw)))
+	n, _ := lz4Compress(raw, block)
+	block = block[:n]
+
+	out := make([]byte, len(raw))
+	for i := 0; i < len(block); i++ {
+		if err := lz4Uncompress(block[:i], out); err == nil {
+			t.Errorf("expected an error with a block truncated to %v bytes", i)
+		}
+	}
+	if err := lz4Uncompress(block, make([]byte, len(raw)-1)); err == nil {
+		t.Errorf("expected an error with a short output buffer")
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		bad := append([]byte(nil), block...)
+		bad[rnd.Intn(len(bad))] = byte(rnd.Intn(256))
+		lz4Uncompress(bad, out)
+	}
+}
+
+func BenchmarkLZ4Compress(b *testing.B) {
+	raw := bytes.Repeat([]byte(`{"level":"info","msg":"request served","status":200}`), 1000)
+	block := make([]byte, lz4CompressBound(len(raw)))
+	b.SetBytes(int64(len(raw)))
+	for i := 0; i < b.N; i++ {
+		lz4Compress(raw, block)
+	}
+}
+
+func BenchmarkLZ4Uncompress(b *testing.B) {
+	raw := bytes.Repeat([]byte(`{"level":"info","msg":"request served","status":200}`), 1000)
+	block := make([]byte, lz4CompressBound(len(raw)))
+	n, _ := lz4Compress(raw, block)
+	b.SetBytes(int64(len(raw)))
+	for i := 0; i < b.N; i++ {
+		lz4Uncompress(block[:n], raw)
+	}
+}
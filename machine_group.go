@@ -1,13 +1,10 @@
 package sls
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
-	"net/http/httputil"
-
-	"github.com/golang/glog"
 )
 
 // MachinGroupAttribute defines machine group attribute
@@ -46,39 +43,31 @@ type MachineList struct {
 
 // ListMachines returns machine list of this machine group.
 func (m *MachineGroup) ListMachines() (ms []*Machine, total int, err error) {
+	return m.ListMachinesWithContext(context.Background())
+}
+
+// ListMachinesWithContext is like ListMachines but uses ctx to cancel the request.
+func (m *MachineGroup) ListMachinesWithContext(ctx context.Context) (ms []*Machine, total int, err error) {
 	h := map[string]string{
 		"x-log-bodyrawsize": "0",
 	}
 
 	uri := fmt.Sprintf("/machinegroups/%v/machines", m.Name)
-	r, err := request(m.project, "GET", uri, h, nil)
+	r, err := request(ctx, m.project, "ListMachines", "GET", uri, h, nil)
 	if err != nil {
 		return
 	}
 
 	buf, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return
-	}
-
-	if r.StatusCode != http.StatusOK {
-		errMsg := &Error{}
-		err = json.Unmarshal(buf, errMsg)
-		if err != nil {
-			err = fmt.Errorf("failed to remove config from machine group")
-			dump, _ := httputil.DumpResponse(r, true)
-			if glog.V(1) {
-				glog.Error(string(dump))
-			}
-			return
-		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		err = m.project.opError(err, "ListMachines", r)
 		return
 	}
 
 	body := &MachineList{}
 	err = json.Unmarshal(buf, body)
 	if err != nil {
+		err = m.project.opError(err, "ListMachines", r)
 		return
 	}
 
@@ -90,6 +79,11 @@ func (m *MachineGroup) ListMachines() (ms []*Machine, total int, err error) {
 
 // GetAppliedConfigs returns applied configs of this machine group.
 func (m *MachineGroup) GetAppliedConfigs() (confNames []string, err error) {
-	confNames, err = m.project.GetAppliedConfigs(m.Name)
+	return m.GetAppliedConfigsWithContext(context.Background())
+}
+
+// GetAppliedConfigsWithContext is like GetAppliedConfigs but uses ctx to cancel the request.
+func (m *MachineGroup) GetAppliedConfigsWithContext(ctx context.Context) (confNames []string, err error) {
+	confNames, err = m.project.GetAppliedConfigsWithContext(ctx, m.Name)
 	return
 }
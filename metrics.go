@@ -0,0 +1,303 @@
+package sls
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics records the measurements of the SDK: the requests of the SDK
+// methods and the gauges of the producers and consumers, e.g. the logs
+// pending in a Producer. MetricsRegistry is the built-in implementation.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveRequest records a request after its last attempt.
+	ObserveRequest(m RequestMetric)
+	// SetGauge sets the value of a gauge. labels are pairs of label names
+	// and values, e.g. "logstore", "app_log".
+	SetGauge(name string, value float64, labels ...string)
+}
+
+// RequestMetric describes a request of an SDK method.
+type RequestMetric struct {
+	Project       string
+	LogStore      string        // Empty for the requests not about a logstore
+	Operation     string        // Name of the SDK method, e.g. "PutLogs"
+	Code          string        // Error code, "OK" if the request succeeded
+	Attempts      int           // Number of attempts, including retries
+	Duration      time.Duration // Time taken by all the attempts
+	RequestBytes  int64         // Size of the body sent, possibly compressed
+	ResponseBytes int64         // Size of the body received, decompressed; -1 if unknown
+}
+
+// Names of the gauges set by the SDK. The gauges of a Producer are labeled
+// with its number in the process, "producer", so that the producers of the
+// same logstore don't overwrite each other.
+const (
+	MetricProducerPendingLogs  = "sls_producer_pending_logs"  // Logs accepted by a Producer and not written yet
+	MetricProducerPendingBytes = "sls_producer_pending_bytes" // Size of the pending logs of a Producer
+	MetricConsumerShards       = "sls_consumer_shards"        // Shards consumed by a ConsumerWorker
+)
+
+var gaugeHelps = map[string]string{
+	MetricProducerPendingLogs:  "Logs accepted by the producer and not written yet.",
+	MetricProducerPendingBytes: "Size in bytes of the logs pending in the producer.",
+	MetricConsumerShards:       "Shards consumed by the consumer worker.",
+}
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the buckets of
+// the request latency histograms of MetricsRegistry.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// requestKey identifies the series of the requests.
+type requestKey struct {
+	project, logStore, operation, code string
+}
+
+// requestSeries aggregates the requests with the same requestKey.
+type requestSeries struct {
+	count         uint64
+	attempts      uint64
+	requestBytes  int64
+	responseBytes int64
+	sum           float64  // Sum of the durations in seconds
+	buckets       []uint64 // Number of requests by bucket, not cumulated
+}
+
+// MetricsRegistry is a Metrics keeping the measurements in memory. It's
+// an http.Handler serving them in the Prometheus text exposition format:
+//
+//	http.Handle("/metrics", registry)
+type MetricsRegistry struct {
+	// Buckets are the upper bounds in seconds of the buckets of the request
+	// latency histograms. nil means DefaultLatencyBuckets. It must not be
+	// changed once the registry is used.
+	Buckets []float64
+
+	mu       sync.Mutex
+	requests map[requestKey]*requestSeries
+	gauges   map[string]map[string]float64 // Values by formatted labels, by name
+}
+
+// NewMetricsRegistry creates an empty registry, like new(MetricsRegistry).
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{}
+}
+
+func (r *MetricsRegistry) buckets() []float64 {
+	if r.Buckets != nil {
+		return r.Buckets
+	}
+	return DefaultLatencyBuckets
+}
+
+// ObserveRequest implements Metrics.
+func (r *MetricsRegistry) ObserveRequest(m RequestMetric) {
+	key := requestKey{project: m.Project, logStore: m.LogStore, operation: m.Operation, code: m.Code}
+	seconds := m.Duration.Seconds()
+	buckets := r.buckets()
+	i := sort.SearchFloat64s(buckets, seconds)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.requests == nil {
+		r.requests = make(map[requestKey]*requestSeries)
+	}
+	s, ok := r.requests[key]
+	if !ok {
+		s = &requestSeries{buckets: make([]uint64, len(buckets))}
+		r.requests[key] = s
+	}
+	s.count++
+	s.attempts += uint64(m.Attempts)
+	s.requestBytes += m.RequestBytes
+	if m.ResponseBytes > 0 {
+		s.responseBytes += m.ResponseBytes
+	}
+	s.sum += seconds
+	if i < len(s.buckets) {
+		s.buckets[i]++
+	}
+}
+
+// SetGauge implements Metrics.
+func (r *MetricsRegistry) SetGauge(name string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gauges == nil {
+		r.gauges = make(map[string]map[string]float64)
+	}
+	series, ok := r.gauges[name]
+	if !ok {
+		series = make(map[string]float64)
+		r.gauges[name] = series
+	}
+	series[formatLabels(labels...)] = value
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format.
+func (r *MetricsRegistry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	keys := make([]requestKey, 0, len(r.requests))
+	for k := range r.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.project != b.project {
+			return a.project < b.project
+		}
+		if a.logStore != b.logStore {
+			return a.logStore < b.logStore
+		}
+		if a.operation != b.operation {
+			return a.operation < b.operation
+		}
+		return a.code < b.code
+	})
+
+	counters := []struct {
+		name, help string
+		value      func(s *requestSeries) string
+	}{
+		{"sls_requests_total", "Requests of the SDK methods, by result code.",
+			func(s *requestSeries) string { return strconv.FormatUint(s.count, 10) }},
+		{"sls_request_attempts_total", "Attempts of the requests, including retries.",
+			func(s *requestSeries) string { return strconv.FormatUint(s.attempts, 10) }},
+		{"sls_request_bytes_total", "Size in bytes of the request bodies sent.",
+			func(s *requestSeries) string { return strconv.FormatInt(s.requestBytes, 10) }},
+		{"sls_response_bytes_total", "Size in bytes of the response bodies received, decompressed.",
+			func(s *requestSeries) string { return strconv.FormatInt(s.responseBytes, 10) }},
+	}
+	for _, c := range counters {
+		if len(keys) == 0 {
+			break
+		}
+		fmt.Fprintf(bw, "# HELP %v %v\n# TYPE %v counter\n", c.name, c.help, c.name)
+		for _, k := range keys {
+			fmt.Fprintf(bw, "%v{%v} %v\n", c.name, k.labels(), c.value(r.requests[k]))
+		}
+	}
+
+	if len(keys) > 0 {
+		const name = "sls_request_duration_seconds"
+		fmt.Fprintf(bw, "# HELP %v Latency of the requests, including retries.\n# TYPE %v histogram\n", name, name)
+		buckets := r.buckets()
+		for _, k := range keys {
+			s, labels := r.requests[k], k.labels()
+			var cumulated uint64
+			for i, le := range buckets {
+				cumulated += s.buckets[i]
+				fmt.Fprintf(bw, "%v_bucket{%v,le=\"%v\"} %v\n", name, labels, formatFloat(le), cumulated)
+			}
+			fmt.Fprintf(bw, "%v_bucket{%v,le=\"+Inf\"} %v\n", name, labels, s.count)
+			fmt.Fprintf(bw, "%v_sum{%v} %v\n", name, labels, formatFloat(s.sum))
+			fmt.Fprintf(bw, "%v_count{%v} %v\n", name, labels, s.count)
+		}
+	}
+
+	names := make([]string, 0, len(r.gauges))
+	for name := range r.gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if help, ok := gaugeHelps[name]; ok {
+			fmt.Fprintf(bw, "# HELP %v %v\n", name, help)
+		}
+		fmt.Fprintf(bw, "# TYPE %v gauge\n", name)
+		series := r.gauges[name]
+		labels := make([]string, 0, len(series))
+		for l := range series {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			if l == "" {
+				fmt.Fprintf(bw, "%v %v\n", name, formatFloat(series[l]))
+			} else {
+				fmt.Fprintf(bw, "%v{%v} %v\n", name, l, formatFloat(series[l]))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func (k requestKey) labels() string {
+	return formatLabels("project", k.project, "logstore", k.logStore, "operation", k.operation, "code", k.code)
+}
+
+// formatLabels formats pairs of label names and values like
+// `name1="value1",name2="value2"`.
+func formatLabels(labels ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// errorCode returns the code recorded for a request failed with err.
+func errorCode(err error) string {
+	switch err {
+	case nil:
+		return "OK"
+	case context.Canceled:
+		return "Canceled"
+	case context.DeadlineExceeded:
+		return "DeadlineExceeded"
+	}
+	if e := slsError(err); e != nil {
+		if e.Code != "" {
+			return e.Code
+		}
+		if e.HTTPCode != 0 {
+			return strconv.Itoa(e.HTTPCode)
+		}
+	}
+	return "ClientError"
+}
+
+// logStoreOf returns the logstore of a request uri like
+// "/logstores/<name>/shards", or "".
+func logStoreOf(uri string) string {
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	if !strings.HasPrefix(uri, "/logstores/") {
+		return ""
+	}
+	name := uri[len("/logstores/"):]
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
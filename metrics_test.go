@@ -0,0 +1,113 @@
+package sls
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRegistryText(t *testing.T) {
+	r := &MetricsRegistry{Buckets: []float64{.1, 1}}
+	r.ObserveRequest(RequestMetric{Project: "p", LogStore: "s", Operation: "PutLogs", Code: "OK",
+		Attempts: 1, Duration: 50 * time.Millisecond, RequestBytes: 100, ResponseBytes: -1})
+	r.ObserveRequest(RequestMetric{Project: "p", LogStore: "s", Operation: "PutLogs", Code: "OK",
+		Attempts: 2, Duration: 500 * time.Millisecond, RequestBytes: 200, ResponseBytes: 0})
+	r.ObserveRequest(RequestMetric{Project: "p", Operation: "ListLogStore", Code: "ServerBusy",
+		Attempts: 3, Duration: 2 * time.Second, ResponseBytes: -1})
+	r.SetGauge(MetricProducerPendingLogs, 7, "project", "p", "logstore", `a"b`)
+
+	expected := `# HELP sls_requests_total Requests of the SDK methods, by result code.
+# TYPE sls_requests_total counter
+sls_requests_total{project="p",logstore="",operation="ListLogStore",code="ServerBusy"} 1
+sls_requests_total{project="p",logstore="s",operation="PutLogs",code="OK"} 2
+# HELP sls_request_attempts_total Attempts of the requests, including retries.
+# TYPE sls_request_attempts_total counter
+sls_request_attempts_total{project="p",logstore="",operation="ListLogStore",code="ServerBusy"} 3
+sls_request_attempts_total{project="p",logstore="s",operation="PutLogs",code="OK"} 3
+# HELP sls_request_bytes_total Size in bytes of the request bodies sent.
+# TYPE sls_request_bytes_total counter
+sls_request_bytes_total{project="p",logstore="",operation="ListLogStore",code="ServerBusy"} 0
+sls_request_bytes_total{project="p",logstore="s",operation="PutLogs",code="OK"} 300
+# HELP sls_response_bytes_total Size in bytes of the response bodies received, decompressed.
+# TYPE sls_response_bytes_total counter
+sls_response_bytes_total{project="p",logstore="",operation="ListLogStore",code="ServerBusy"} 0
+sls_response_bytes_total{project="p",logstore="s",operation="PutLogs",code="OK"} 0
+# HELP sls_request_duration_seconds Latency of the requests, including retries.
+# TYPE sls_request_duration_seconds histogram
+sls_request_duration_seconds_bucket{project="p",logstore="",operation="ListLogStore",code="ServerBusy",le="0.1"} 0
+sls_request_duration_seconds_bucket{project="p",logstore="",operation="ListLogStore",code="ServerBusy",le="1"} 0
+sls_request_duration_seconds_bucket{project="p",logstore="",operation="ListLogStore",code="ServerBusy",le="+Inf"} 1
+sls_request_duration_seconds_sum{project="p",logstore="",operation="ListLogStore",code="ServerBusy"} 2
+sls_request_duration_seconds_count{project="p",logstore="",operation="ListLogStore",code="ServerBusy"} 1
+sls_request_duration_seconds_bucket{project="p",logstore="s",operation="PutLogs",code="OK",le="0.1"} 1
+sls_request_duration_seconds_bucket{project="p",logstore="s",operation="PutLogs",code="OK",le="1"} 2
+sls_request_duration_seconds_bucket{project="p",logstore="s",operation="PutLogs",code="OK",le="+Inf"} 2
+sls_request_duration_seconds_sum{project="p",logstore="s",operation="PutLogs",code="OK"} 0.55
+sls_request_duration_seconds_count{project="p",logstore="s",operation="PutLogs",code="OK"} 2
+# HELP sls_producer_pending_logs Logs accepted by the producer and not written yet.
+# TYPE sls_producer_pending_logs gauge
+sls_producer_pending_logs{project="p",logstore="a\"b"} 7
+`
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("unexpected exposition:\n%v", buf.String())
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if rec.Body.String() != expected {
+		t.Errorf("unexpected body:\n%v", rec.Body.String())
+	}
+}
+
+func TestMetricsOfRequests(t *testing.T) {
+	rt := &scriptTransport{script: []*Error{
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+	}}
+	p := newRetryTestProject(rt)
+	registry := NewMetricsRegistry()
+	p.WithMetrics(registry)
+
+	store := &LogStore{Name: "app_log", project: p}
+	if _, err := store.GetCursor(0, "end"); err == nil {
+		t.Fatal("expected an error")
+	}
+	p.ListLogStore()
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+	for _, line := range []string{
+		`sls_requests_total{project="test-retry",logstore="app_log",operation="GetCursor",code="ServerBusy"} 1`,
+		`sls_request_attempts_total{project="test-retry",logstore="app_log",operation="GetCursor",code="ServerBusy"} 3`,
+		`sls_requests_total{project="test-retry",logstore="",operation="ListLogStore",code="OK"} 1`,
+		`sls_response_bytes_total{project="test-retry",logstore="",operation="ListLogStore",code="OK"} 2`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing %v in:\n%v", line, buf.String())
+		}
+	}
+}
+
+func TestLogStoreOf(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"/logstores/app_log/shards/0?type=cursor&from=end": "app_log",
+		"/logstores/app_log":        "app_log",
+		"/logstores?offset=0":       "",
+		"/machinegroups/g/machines": "",
+	} {
+		if got := logStoreOf(uri); got != expected {
+			t.Errorf("logStoreOf(%q) = %q, expected %q", uri, got, expected)
+		}
+	}
+}
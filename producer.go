@@ -0,0 +1,344 @@
+package sls
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// Default values of ProducerConfig.
+const (
+	DefaultProducerMaxBatchCount = 4096
+	DefaultProducerMaxBatchSize  = 512 * 1024
+	DefaultProducerLingerTime    = 2 * time.Second
+	DefaultProducerMaxInFlight   = 4
+)
+
+// ErrProducerClosed is returned by Producer.Send after Close is called.
+var ErrProducerClosed = NewClientError("producer is closed")
+
+// ProducerResult is the result of sending a batch of logs.
+type ProducerResult struct {
+	Topic    string
+	Source   string
+	Logs     []*Log
+	Size     int   // Size in bytes of the marshaled logs
+	Attempts int   // Number of PutLogs calls made for the batch
+	Err      error // Last error if the batch couldn't be sent, nil otherwise
+}
+
+// ProducerConfig defines how a Producer batches and sends logs.
+// The zero value of a field means its default value.
+type ProducerConfig struct {
+	MaxBatchCount int           // Max number of logs in a batch
+	MaxBatchSize  int           // Max size in bytes of the logs in a batch
+	LingerTime    time.Duration // Max time a log waits in a batch before the batch is sent
+	MaxInFlight   int           // Max number of concurrent PutLogs calls
+
+	// RetryPolicy decides which failed batches are sent again, as
+	// idempotent requests, nil means DefaultRetryPolicy.
+	RetryPolicy RetryPolicy
+
+	// Callback is called with the result of each batch, from the goroutine
+	// that sent it. It should not block.
+	Callback func(result *ProducerResult)
+}
+
+// batch is a batch of logs with the same topic and source.
+type batch struct {
+	topic   string
+	source  string
+	logs    []*Log
+	size    int
+	created time.Time
+}
+
+type batchKey struct {
+	topic  string
+	source string
+}
+
+// Producer batches logs into log groups and writes them asynchronously
+// into a logstore with PutLogs.
+//
+// A batch is sent as soon as it reaches MaxBatchCount logs or MaxBatchSize
+// bytes, or when its oldest log has waited for LingerTime. At most
+// MaxInFlight batches are sent concurrently, Send blocks when they're all
+// busy. Close sends the pending batches and waits for them.
+//
+// Each PutLogs call is retried according to the RetryPolicy of the project
+// of the logstore, then the failed batches are sent again according to the
+// RetryPolicy of the producer. Unlike the project, the producer also
+// retries the server errors and the broken connections, after which the
+// batch may have been written already: the logs are written at least once
+// and may be duplicated. Use NoRetryPolicy to report these failures to the
+// Callback instead.
+type Producer struct {
+	store  *LogStore
+	config ProducerConfig
+	id     string // Value of the "producer" label of the gauges
+
+	mu      sync.Mutex
+	batches map[batchKey]*batch
+	closed  bool
+
+	pendingLogs  int // Logs accepted by Send and not written yet
+	pendingBytes int
+
+	ctx    context.Context // Context of the PutLogs calls, canceled by CloseWithContext
+	cancel context.CancelFunc
+
+	queue   chan *batch
+	stop    chan struct{}
+	pending sync.WaitGroup // Batches not reported to Callback yet
+	workers sync.WaitGroup
+}
+
+// producerCount is the number of producers created, it numbers them.
+var producerCount uint64
+
+// NewProducer creates a producer writing into logstore s and starts it.
+func NewProducer(s *LogStore, config ProducerConfig) *Producer {
+	if config.MaxBatchCount <= 0 {
+		config.MaxBatchCount = DefaultProducerMaxBatchCount
+	}
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = DefaultProducerMaxBatchSize
+	}
+	if config.LingerTime <= 0 {
+		config.LingerTime = DefaultProducerLingerTime
+	}
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = DefaultProducerMaxInFlight
+	}
+	if config.RetryPolicy == nil {
+		config.RetryPolicy = DefaultRetryPolicy
+	}
+
+	p := &Producer{
+		store:   s,
+		config:  config,
+		id:      strconv.FormatUint(atomic.AddUint64(&producerCount, 1), 10),
+		batches: make(map[batchKey]*batch),
+		queue:   make(chan *batch),
+		stop:    make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.workers.Add(config.MaxInFlight + 1)
+	for i := 0; i < config.MaxInFlight; i++ {
+		go p.sendLoop()
+	}
+	go p.lingerLoop()
+	return p
+}
+
+// Send adds log l with the given topic and source to a batch.
+// A log without time is stamped with the current time.
+func (p *Producer) Send(topic, source string, l *Log) error {
+	if l.Time == nil {
+		l.Time = proto.Uint32(uint32(time.Now().Unix()))
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrProducerClosed
+	}
+
+	key := batchKey{topic: topic, source: source}
+	size := l.Size()
+	var full []*batch
+	b, ok := p.batches[key]
+	if ok && b.size+size > p.config.MaxBatchSize {
+		// The log doesn't fit in the batch, which is sent before.
+		full = append(full, b)
+		ok = false
+	}
+	if !ok {
+		b = &batch{topic: topic, source: source, created: time.Now()}
+		p.batches[key] = b
+	}
+	b.logs = append(b.logs, l)
+	b.size += size
+	p.addPending(1, size)
+
+	if len(b.logs) >= p.config.MaxBatchCount || b.size >= p.config.MaxBatchSize {
+		full = append(full, b)
+		delete(p.batches, key)
+	}
+	p.pending.Add(len(full))
+	p.mu.Unlock()
+
+	for _, b := range full {
+		p.queue <- b
+	}
+	return nil
+}
+
+// Close sends the pending batches, waits for all batches to be reported
+// and stops the producer. Send fails with ErrProducerClosed afterwards.
+func (p *Producer) Close() error {
+	return p.CloseWithContext(context.Background())
+}
+
+// CloseWithContext is like Close but gives up when ctx is done: the
+// batches being sent are canceled, the ones not sent yet are reported with
+// the error of ctx, which CloseWithContext returns.
+func (p *Producer) CloseWithContext(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	var batches []*batch
+	for key, b := range p.batches {
+		batches = append(batches, b)
+		delete(p.batches, key)
+	}
+	p.pending.Add(len(batches))
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.pending.Wait()
+		close(done)
+	}()
+	var err error
+	for i, b := range batches {
+		select {
+		case p.queue <- b:
+		case <-ctx.Done():
+			err = ctx.Err()
+			for _, b := range batches[i:] {
+				p.report(b, 0, err)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		p.cancel()
+		<-done
+	}
+	p.cancel()
+	close(p.stop)
+	p.workers.Wait()
+	return err
+}
+
+// lingerLoop sends the batches whose oldest log has waited for LingerTime.
+func (p *Producer) lingerLoop() {
+	defer p.workers.Done()
+
+	tick := p.config.LingerTime / 2
+	if tick < 10*time.Millisecond {
+		tick = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			var expired []*batch
+			p.mu.Lock()
+			for key, b := range p.batches {
+				if now.Sub(b.created) >= p.config.LingerTime {
+					expired = append(expired, b)
+					delete(p.batches, key)
+				}
+			}
+			p.pending.Add(len(expired))
+			p.mu.Unlock()
+
+			for _, b := range expired {
+				select {
+				case p.queue <- b:
+				case <-p.stop:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *Producer) sendLoop() {
+	defer p.workers.Done()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case b := <-p.queue:
+			p.send(b)
+		}
+	}
+}
+
+// send writes batch b, retrying according to the producer's RetryPolicy,
+// and reports the result.
+func (p *Producer) send(b *batch) {
+	lg := &LogGroup{
+		Topic:  proto.String(b.topic),
+		Source: proto.String(b.source),
+		Logs:   b.logs,
+	}
+	var attempts int
+	var err error
+	for {
+		attempts++
+		err = p.store.PutLogsWithContext(p.ctx, lg)
+		if err == nil {
+			break
+		}
+		delay, retry := p.config.RetryPolicy.Backoff(attempts, true, err)
+		if !retry || !sleepContext(p.ctx, delay) {
+			break
+		}
+	}
+	p.report(b, attempts, err)
+}
+
+// report reports the result of batch b after attempts PutLogs calls.
+func (p *Producer) report(b *batch, attempts int, err error) {
+	defer p.pending.Done()
+
+	result := &ProducerResult{
+		Topic:    b.topic,
+		Source:   b.source,
+		Logs:     b.logs,
+		Size:     b.size,
+		Attempts: attempts,
+		Err:      err,
+	}
+
+	p.mu.Lock()
+	p.addPending(-len(b.logs), -b.size)
+	p.mu.Unlock()
+
+	if p.config.Callback != nil {
+		p.config.Callback(result)
+	}
+}
+
+// addPending updates the number and size of the pending logs and their
+// gauges, p.mu must be held.
+func (p *Producer) addPending(logs, bytes int) {
+	p.pendingLogs += logs
+	p.pendingBytes += bytes
+	if m := p.store.project.Metrics; m != nil {
+		labels := []string{"project", p.store.project.Name, "logstore", p.store.Name, "producer", p.id}
+		m.SetGauge(MetricProducerPendingLogs, float64(p.pendingLogs), labels...)
+		m.SetGauge(MetricProducerPendingBytes, float64(p.pendingBytes), labels...)
+	}
+}
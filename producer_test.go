@@ -0,0 +1,244 @@
+package sls_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	sls "github.com/galaxydi/go-loghub"
+	"github.com/galaxydi/go-loghub/slstest"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestProducer(t *testing.T) {
+	suite.Run(t, new(ProducerTestSuite))
+}
+
+type ProducerTestSuite struct {
+	suite.Suite
+	server *slstest.Server
+	store  *sls.LogStore
+
+	mu      sync.Mutex
+	results []*sls.ProducerResult
+}
+
+func (s *ProducerTestSuite) SetupTest() {
+	s.server, s.store = slstest.NewLogStore(s.T(), 1)
+	s.results = nil
+}
+
+func (s *ProducerTestSuite) callback(result *sls.ProducerResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result)
+}
+
+func newLog(i int) *sls.Log {
+	return &sls.Log{
+		Contents: []*sls.LogContent{{
+			Key:   proto.String("index"),
+			Value: proto.String(fmt.Sprint(i)),
+		}},
+	}
+}
+
+// pulledLogs returns the number of logs in the log groups of shard 0.
+func (s *ProducerTestSuite) pulledLogs() (groups, logs int) {
+	gl := slstest.PullLogs(s.T(), s.store, 0)
+	for _, lg := range gl {
+		logs += len(lg.Logs)
+	}
+	return len(gl), logs
+}
+
+func (s *ProducerTestSuite) TestBatchByCount() {
+	p := sls.NewProducer(s.store, sls.ProducerConfig{
+		MaxBatchCount: 10,
+		LingerTime:    time.Hour,
+		Callback:      s.callback,
+	})
+	for i := 0; i < 25; i++ {
+		s.Nil(p.Send("topic", "127.0.0.1", newLog(i)))
+	}
+	s.Nil(p.Close())
+
+	groups, logs := s.pulledLogs()
+	s.Equal(3, groups)
+	s.Equal(25, logs)
+	s.Len(s.results, 3)
+	for _, r := range s.results {
+		s.Nil(r.Err)
+		s.Equal("topic", r.Topic)
+	}
+}
+
+func (s *ProducerTestSuite) TestBatchBySize() {
+	// Two logs fit in a batch, the third one would overflow it.
+	logs := make([]*sls.Log, 5)
+	for i := range logs {
+		logs[i] = newLog(i)
+		logs[i].Time = proto.Uint32(1500000000)
+	}
+	size := logs[0].Size()
+	p := sls.NewProducer(s.store, sls.ProducerConfig{
+		MaxBatchSize: 3*size - 1,
+		LingerTime:   time.Hour,
+		Callback:     s.callback,
+	})
+	for _, l := range logs {
+		s.Nil(p.Send("topic", "127.0.0.1", l))
+	}
+	s.Nil(p.Close())
+
+	groups, n := s.pulledLogs()
+	s.Equal(3, groups)
+	s.Equal(5, n)
+	for _, r := range s.results {
+		s.Nil(r.Err)
+		s.True(r.Size <= 3*size-1, "batch of %v bytes", r.Size)
+	}
+}
+
+func (s *ProducerTestSuite) TestBatchByTopicAndSource() {
+	p := sls.NewProducer(s.store, sls.ProducerConfig{Callback: s.callback})
+	s.Nil(p.Send("a", "127.0.0.1", newLog(0)))
+	s.Nil(p.Send("b", "127.0.0.1", newLog(1)))
+	s.Nil(p.Send("a", "127.0.0.2", newLog(2)))
+	s.Nil(p.Send("a", "127.0.0.1", newLog(3)))
+	s.Nil(p.Close())
+
+	groups, logs := s.pulledLogs()
+	s.Equal(3, groups)
+	s.Equal(4, logs)
+}
+
+func (s *ProducerTestSuite) TestLinger() {
+	p := sls.NewProducer(s.store, sls.ProducerConfig{
+		LingerTime: 20 * time.Millisecond,
+		Callback:   s.callback,
+	})
+	defer p.Close()
+	s.Nil(p.Send("topic", "127.0.0.1", newLog(0)))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		n := len(s.results)
+		s.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, logs := s.pulledLogs()
+	s.Equal(1, logs)
+}
+
+func (s *ProducerTestSuite) TestSendAfterClose() {
+	p := sls.NewProducer(s.store, sls.ProducerConfig{})
+	s.Nil(p.Close())
+	s.Equal(sls.ErrProducerClosed, p.Send("topic", "127.0.0.1", newLog(0)))
+}
+
+func (s *ProducerTestSuite) TestFailedBatch() {
+	project := s.server.NewProject("test-project")
+	project.WithRetryPolicy(sls.NoRetryPolicy)
+	store, err := project.GetLogStore("test-logstore")
+	s.Nil(err)
+	s.Nil(project.DeleteLogStore("test-logstore"))
+
+	p := sls.NewProducer(store, sls.ProducerConfig{Callback: s.callback})
+	s.Nil(p.Send("topic", "127.0.0.1", newLog(0)))
+	s.Nil(p.Close())
+
+	s.Len(s.results, 1)
+	s.NotNil(s.results[0].Err)
+	s.Equal(1, s.results[0].Attempts)
+}
+
+func (s *ProducerTestSuite) TestRetriedBatch() {
+	// The first PutLogs call fails with a server error, which the project
+	// doesn't retry as the logs may have been written.
+	var failed bool
+	project := s.server.NewProject("test-project")
+	project.WithInterceptors(func(ctx context.Context, req *sls.Request, next sls.Handler) (*http.Response, error) {
+		if req.Operation == "PutLogs" && !failed {
+			failed = true
+			return nil, &sls.Error{HTTPCode: http.StatusInternalServerError, Code: "InternalServerError"}
+		}
+		return next(ctx, req)
+	})
+	store, err := project.GetLogStore("test-logstore")
+	s.Nil(err)
+
+	p := sls.NewProducer(store, sls.ProducerConfig{
+		RetryPolicy: &sls.BackoffPolicy{MaxAttempts: 3},
+		Callback:    s.callback,
+	})
+	s.Nil(p.Send("topic", "127.0.0.1", newLog(0)))
+	s.Nil(p.Close())
+
+	s.Len(s.results, 1)
+	s.Nil(s.results[0].Err)
+	s.Equal(2, s.results[0].Attempts)
+	s.Len(slstest.PullLogs(s.T(), s.store, 0), 1)
+}
+
+func (s *ProducerTestSuite) TestCloseWithCanceledContext() {
+	p := sls.NewProducer(s.store, sls.ProducerConfig{
+		LingerTime: time.Hour,
+		Callback:   s.callback,
+	})
+	s.Nil(p.Send("topic", "127.0.0.1", newLog(0)))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Equal(context.Canceled, p.CloseWithContext(ctx))
+
+	// The batch is reported either way, sent or canceled.
+	s.Len(s.results, 1)
+	s.Equal(sls.ErrProducerClosed, p.Send("topic", "127.0.0.1", newLog(1)))
+}
+
+func (s *ProducerTestSuite) TestMetrics() {
+	registry := sls.NewMetricsRegistry()
+	project := s.server.NewProject("test-project")
+	project.WithMetrics(registry)
+	store, err := project.GetLogStore("test-logstore")
+	s.Nil(err)
+
+	text := func() string {
+		var buf bytes.Buffer
+		s.Nil(registry.WriteText(&buf))
+		return buf.String()
+	}
+	pending := func(name string) []string {
+		re := regexp.MustCompile(name + `\{project="test-project",logstore="test-logstore",producer="\d+"\} (\d+)\n`)
+		var values []string
+		for _, m := range re.FindAllStringSubmatch(text(), -1) {
+			values = append(values, m[1])
+		}
+		sort.Strings(values)
+		return values
+	}
+	// The producers of a logstore have their own gauges.
+	p := sls.NewProducer(store, sls.ProducerConfig{LingerTime: time.Hour})
+	p2 := sls.NewProducer(store, sls.ProducerConfig{LingerTime: time.Hour})
+	for i := 0; i < 3; i++ {
+		s.Nil(p.Send("topic", "127.0.0.1", newLog(i)))
+	}
+	s.Nil(p2.Send("topic", "127.0.0.1", newLog(3)))
+	s.Equal([]string{"1", "3"}, pending("sls_producer_pending_logs"))
+	s.Nil(p.Close())
+	s.Nil(p2.Close())
+	s.Equal([]string{"0", "0"}, pending("sls_producer_pending_logs"))
+	s.Equal([]string{"0", "0"}, pending("sls_producer_pending_bytes"))
+	s.Contains(text(), `sls_requests_total{project="test-project",logstore="test-logstore",operation="PutLogs",code="OK"} 2`+"\n")
+}
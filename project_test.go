@@ -1,36 +1,30 @@
-package sls
+package sls_test
 
 import (
-	"os"
 	"testing"
 
-	"github.com/golang/glog"
+	sls "github.com/galaxydi/go-loghub"
+	"github.com/galaxydi/go-loghub/slstest"
 	"github.com/stretchr/testify/suite"
 )
 
 func TestProject(t *testing.T) {
 	suite.Run(t, new(ProjectTestSuite))
-	glog.Flush()
 }
 
 type ProjectTestSuite struct {
 	suite.Suite
-	endpoint        string
-	accessKeyID     string
-	accessKeySecret string
-	client          Client
+	server *slstest.Server
+	client *sls.Client
 }
 
 func (s *ProjectTestSuite) SetupTest() {
-	s.endpoint = os.Getenv("LOG_TEST_ENDPOINT")
-	s.accessKeyID = os.Getenv("LOG_TEST_ACCESS_KEY_ID")
-	s.accessKeySecret = os.Getenv("LOG_TEST_ACCESS_KEY_SECRET")
-	s.client = Client{
-		Endpoint:        s.endpoint,
-		AccessKeyID:     s.accessKeyID,
-		AccessKeySecret: s.accessKeySecret,
-		SecurityToken:   "",
-	}
+	s.server = slstest.NewServer("mockAccessKeyID", "mockAccessKeySecret")
+	s.client = s.server.NewClient()
+}
+
+func (s *ProjectTestSuite) TearDownTest() {
+	s.server.Close()
 }
 
 func (s *ProjectTestSuite) TestCheckProjectExist() {
@@ -38,4 +32,10 @@ func (s *ProjectTestSuite) TestCheckProjectExist() {
 	exist, err := s.client.CheckProjectExist(projectName)
 	s.Nil(err)
 	s.False(exist)
+
+	_, err = s.client.CreateProject(projectName, "")
+	s.Nil(err)
+	exist, err = s.client.CheckProjectExist(projectName)
+	s.Nil(err)
+	s.True(exist)
 }
@@ -0,0 +1,262 @@
+package sls
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit is the limit of a token bucket. The buckets start full and
+// hold up to one second of traffic, so short bursts are sent at once.
+type RateLimit struct {
+	RequestsPerSecond float64 // Max number of attempts per second, 0 means unlimited
+	BytesPerSecond    float64 // Max size of the request bodies sent per second, 0 means unlimited
+
+	// Adaptive lowers the rates when SLS rejects the requests because a
+	// quota is exceeded, e.g. WriteQuotaExceed, and raises them back to
+	// the limit as the requests succeed again.
+	Adaptive bool
+}
+
+// Factors of the adaptive rates.
+const (
+	adaptiveDecrease = 0.5      // A quota error halves the rate
+	adaptiveIncrease = 1.0 / 20 // A success adds 5% of the limit to the rate
+	adaptiveMinimum  = 1.0 / 16 // The rate never drops below 1/16 of the limit
+)
+
+// RateLimiter throttles the requests of projects on the client side, before
+// SLS does, with token buckets: one shared by all the requests of a project,
+// one for each logstore and one for each shard. Each attempt of a request
+// waits for the buckets it goes through, the request addressing a shard by
+// its ID, e.g. PullLogs, go through the bucket of the shard.
+// A RateLimiter can be shared by several projects, their buckets are apart.
+// Its limits are set before it's used, then with SetLimits and
+// SetLogStoreLimit, the buckets follow them at their next refill.
+type RateLimiter struct {
+	Project  RateLimit // Limit of all the requests of a project
+	LogStore RateLimit // Limit of the requests of each logstore, unless in LogStores
+	Shard    RateLimit // Limit of the requests of each shard
+
+	// LogStores are the limits of the given logstores, instead of LogStore.
+	LogStores map[string]RateLimit
+
+	mu      sync.Mutex
+	buckets map[limitKey]*limitBuckets
+}
+
+// limitKey identifies the buckets of a project (logStore is ""), a
+// logstore (shard is -1) or a shard.
+type limitKey struct {
+	project  string
+	logStore string
+	shard    int
+}
+
+type limitBuckets struct {
+	limit    RateLimit
+	requests *tokenBucket // nil if unlimited
+	bytes    *tokenBucket // nil if unlimited
+}
+
+// NewRateLimiter creates a limiter of the requests of each project and
+// each logstore. The other limits can be set before it's used.
+func NewRateLimiter(project, logStore RateLimit) *RateLimiter {
+	return &RateLimiter{Project: project, LogStore: logStore}
+}
+
+// SetLimits changes the limits of the projects, logstores and shards of a
+// limiter in use.
+func (l *RateLimiter) SetLimits(project, logStore, shard RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Project, l.LogStore, l.Shard = project, logStore, shard
+}
+
+// SetLogStoreLimit changes the limit of logstore name of a limiter in use,
+// see LogStores.
+func (l *RateLimiter) SetLogStoreLimit(name string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// LogStores is copied as it may be shared by other limiters.
+	logStores := make(map[string]RateLimit, len(l.LogStores)+1)
+	for store, limit := range l.LogStores {
+		logStores[store] = limit
+	}
+	logStores[name] = limit
+	l.LogStores = logStores
+}
+
+// limits returns the keys and limits of the buckets of a request, l.mu
+// must be held once l is used.
+func (l *RateLimiter) limits(project, uri string) ([]limitKey, []RateLimit) {
+	keys := []limitKey{{project: project, shard: -1}}
+	limits := []RateLimit{l.Project}
+	if store := logStoreOf(uri); store != "" {
+		limit, ok := l.LogStores[store]
+		if !ok {
+			limit = l.LogStore
+		}
+		keys = append(keys, limitKey{project: project, logStore: store, shard: -1})
+		limits = append(limits, limit)
+		if shard, ok := shardOf(uri); ok {
+			keys = append(keys, limitKey{project: project, logStore: store, shard: shard})
+			limits = append(limits, l.Shard)
+		}
+	}
+	return keys, limits
+}
+
+// bucketsOf returns the buckets of key with the current limit, l.mu must
+// be held.
+func (l *RateLimiter) bucketsOf(key limitKey, limit RateLimit, now time.Time) *limitBuckets {
+	if l.buckets == nil {
+		l.buckets = make(map[limitKey]*limitBuckets)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &limitBuckets{}
+		l.buckets[key] = b
+	}
+	if !ok || b.limit != limit {
+		b.limit = limit
+		b.requests = b.requests.withLimit(limit.RequestsPerSecond, limit.Adaptive, now)
+		b.bytes = b.bytes.withLimit(limit.BytesPerSecond, limit.Adaptive, now)
+	}
+	return b
+}
+
+// reservation is a number of tokens taken from a bucket.
+type reservation struct {
+	bucket *tokenBucket
+	n      float64
+}
+
+// wait waits for the buckets of an attempt of req, sent by project. It
+// returns ctx.Err() if ctx is done before, the tokens taken are given back.
+func (l *RateLimiter) wait(ctx context.Context, project string, req *Request) error {
+	now := time.Now()
+	var delay time.Duration
+	var reserved []reservation
+	reserve := func(tb *tokenBucket, n float64) {
+		if tb == nil {
+			return
+		}
+		if d := tb.reserve(n, now); d > delay {
+			delay = d
+		}
+		reserved = append(reserved, reservation{tb, n})
+	}
+
+	l.mu.Lock()
+	keys, limits := l.limits(project, req.URI)
+	for i, key := range keys {
+		b := l.bucketsOf(key, limits[i], now)
+		reserve(b.requests, 1)
+		reserve(b.bytes, float64(len(req.Body)))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 && !sleepContext(ctx, delay) {
+		l.mu.Lock()
+		for _, r := range reserved {
+			r.bucket.tokens += r.n
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+	return nil
+}
+
+// observe adapts the adaptive rates of the buckets of req to the result
+// of an attempt.
+func (l *RateLimiter) observe(project string, req *Request, err error) {
+	quotaExceeded := IsQuotaExceeded(err)
+	if err != nil && !quotaExceeded {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys, _ := l.limits(project, req.URI)
+	for _, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok || !b.limit.Adaptive {
+			continue
+		}
+		for _, tb := range []*tokenBucket{b.requests, b.bytes} {
+			if tb == nil {
+				continue
+			}
+			if quotaExceeded {
+				tb.slowDown()
+			} else {
+				tb.speedUp()
+			}
+		}
+	}
+}
+
+// tokenBucket is a token bucket refilled at rate tokens per second, up to
+// rate tokens. Its tokens may be negative, i.e. reserved ahead.
+type tokenBucket struct {
+	limit  float64 // Configured rate
+	rate   float64 // Current rate, lower than limit when slowed down
+	tokens float64
+	last   time.Time // Time of the last refill
+}
+
+func newTokenBucket(limit float64, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: limit, rate: limit, tokens: limit, last: now}
+}
+
+// withLimit returns bucket b, possibly nil, changed to limit, or nil if
+// limit is 0, i.e. unlimited. The rate of an adaptive bucket keeps its
+// ratio to the limit.
+func (b *tokenBucket) withLimit(limit float64, adaptive bool, now time.Time) *tokenBucket {
+	switch {
+	case limit <= 0:
+		return nil
+	case b == nil:
+		return newTokenBucket(limit, now)
+	case !adaptive:
+		b.rate = limit
+	default:
+		b.rate *= limit / b.limit
+	}
+	b.limit = limit
+	return b
+}
+
+// reserve takes n tokens at time now and returns how long to wait until
+// they're refilled.
+func (b *tokenBucket) reserve(n float64, now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		b.last = now
+	}
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) slowDown() {
+	b.rate *= adaptiveDecrease
+	if min := b.limit * adaptiveMinimum; b.rate < min {
+		b.rate = min
+	}
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+}
+
+func (b *tokenBucket) speedUp() {
+	b.rate += b.limit * adaptiveIncrease
+	if b.rate > b.limit {
+		b.rate = b.limit
+	}
+}
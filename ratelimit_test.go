@@ -0,0 +1,163 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	b := newTokenBucket(10, now)
+
+	// The bucket starts full.
+	for i := 0; i < 10; i++ {
+		if d := b.reserve(1, now); d != 0 {
+			t.Fatalf("request %v waits %v", i, d)
+		}
+	}
+	if d := b.reserve(1, now); d != 100*time.Millisecond {
+		t.Errorf("expected to wait 100ms, got %v", d)
+	}
+	// Reserved tokens are refilled first.
+	if d := b.reserve(1, now.Add(100*time.Millisecond)); d != 100*time.Millisecond {
+		t.Errorf("expected to wait 100ms, got %v", d)
+	}
+	// The bucket holds one second of tokens at most.
+	now = now.Add(time.Hour)
+	if d := b.reserve(10, now); d != 0 {
+		t.Errorf("expected no wait, got %v", d)
+	}
+	if d := b.reserve(5, now); d != 500*time.Millisecond {
+		t.Errorf("expected to wait 500ms, got %v", d)
+	}
+}
+
+func TestTokenBucketAdaptive(t *testing.T) {
+	b := newTokenBucket(160, time.Now())
+	for i := 0; i < 10; i++ {
+		b.slowDown()
+	}
+	if b.rate != 10 {
+		t.Errorf("expected the rate to stop at 1/16 of the limit, got %v", b.rate)
+	}
+	if b.tokens > b.rate {
+		t.Errorf("the bucket holds %v tokens, more than its rate", b.tokens)
+	}
+	for i := 0; i < 30; i++ {
+		b.speedUp()
+	}
+	if b.rate != 160 {
+		t.Errorf("expected the rate to recover to the limit, got %v", b.rate)
+	}
+}
+
+func TestRateLimiterBuckets(t *testing.T) {
+	l := &RateLimiter{
+		Project:   RateLimit{RequestsPerSecond: 100},
+		LogStore:  RateLimit{BytesPerSecond: 1024},
+		Shard:     RateLimit{RequestsPerSecond: 5, Adaptive: true},
+		LogStores: map[string]RateLimit{"big": {BytesPerSecond: 4096}},
+	}
+	keys, limits := l.limits("p", "/logstores/big/shards/1?type=log")
+	expected := []limitKey{{"p", "", -1}, {"p", "big", -1}, {"p", "big", 1}}
+	if len(keys) != len(expected) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Errorf("unexpected key %v, expected %v", keys[i], expected[i])
+		}
+	}
+	if limits[1].BytesPerSecond != 4096 || limits[2].RequestsPerSecond != 5 {
+		t.Errorf("unexpected limits: %v", limits)
+	}
+
+	keys, _ = l.limits("p", "/logstores?offset=0")
+	if len(keys) != 1 {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+
+	// Quota errors slow down the adaptive buckets only.
+	req := &Request{URI: "/logstores/big/shards/1?type=log"}
+	if err := l.wait(context.Background(), "p", req); err != nil {
+		t.Fatal(err)
+	}
+	l.observe("p", req, &Error{Code: "ShardReadQuotaExceed", HTTPCode: http.StatusForbidden})
+	if rate := l.buckets[limitKey{"p", "big", 1}].requests.rate; rate != 2.5 {
+		t.Errorf("expected the shard rate to be halved, got %v", rate)
+	}
+	if rate := l.buckets[limitKey{"p", "", -1}].requests.rate; rate != 100 {
+		t.Errorf("expected the project rate to be kept, got %v", rate)
+	}
+	l.observe("p", req, &Error{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable})
+	if rate := l.buckets[limitKey{"p", "big", 1}].requests.rate; rate != 2.5 {
+		t.Errorf("expected the shard rate to be kept, got %v", rate)
+	}
+	l.observe("p", req, nil)
+	if rate := l.buckets[limitKey{"p", "big", 1}].requests.rate; rate != 2.75 {
+		t.Errorf("expected the shard rate to be raised, got %v", rate)
+	}
+}
+
+func TestRateLimiterThrottlesRequests(t *testing.T) {
+	rt := &scriptTransport{}
+	p := newRetryTestProject(rt)
+	l := &RateLimiter{Shard: RateLimit{RequestsPerSecond: 1}}
+	p.WithRateLimiter(l)
+	store := &LogStore{Name: "app_log", project: p}
+
+	if _, err := store.GetCursor(0, "end"); err != nil {
+		t.Fatal(err)
+	}
+	// Another shard has its own bucket.
+	if _, err := store.GetCursor(1, "end"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := store.GetCursorWithContext(ctx, 0, "end"); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if len(rt.reqs) != 2 {
+		t.Errorf("expected 2 requests, got %v", len(rt.reqs))
+	}
+	// The token of the canceled request is given back.
+	if tokens := l.buckets[limitKey{p.Name, "app_log", 0}].requests.tokens; tokens < -0.5 {
+		t.Errorf("expected the token to be given back, the bucket holds %v tokens", tokens)
+	}
+
+	// The new limits apply to the existing buckets.
+	l.SetLimits(RateLimit{}, RateLimit{}, RateLimit{RequestsPerSecond: 1000})
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := store.GetCursorWithContext(ctx, 0, "end"); err != nil {
+		t.Error(err)
+	}
+	l.SetLogStoreLimit("app_log", RateLimit{RequestsPerSecond: 1000, Adaptive: true})
+	if limit := l.buckets[limitKey{p.Name, "app_log", -1}].limit; limit.RequestsPerSecond != 0 {
+		t.Errorf("the logstore bucket has limit %v before its next refill", limit)
+	}
+	if _, err := store.GetCursor(0, "end"); err != nil {
+		t.Error(err)
+	}
+	if b := l.buckets[limitKey{p.Name, "app_log", -1}]; b.requests == nil || b.requests.limit != 1000 {
+		t.Errorf("expected the logstore bucket to be limited to 1000 requests per second")
+	}
+}
+
+func TestTokenBucketWithLimit(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(100, now)
+	b.slowDown()
+	if b = b.withLimit(200, true, now); b.rate != 100 {
+		t.Errorf("expected the adaptive rate to keep its ratio to the limit, got %v", b.rate)
+	}
+	if b = b.withLimit(50, false, now); b.rate != 50 {
+		t.Errorf("expected the rate to be the limit, got %v", b.rate)
+	}
+	if b = b.withLimit(0, false, now); b != nil {
+		t.Errorf("expected no bucket without limit")
+	}
+}
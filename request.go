@@ -2,20 +2,155 @@ package sls
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
-	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
 
 	"encoding/json"
 	"io/ioutil"
+)
 
-	"github.com/golang/glog"
+// Default timeouts of defaultHTTPClient.
+const (
+	defaultDialTimeout           = 10 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultResponseHeaderTimeout = 60 * time.Second
+	defaultRequestTimeout        = 120 * time.Second
 )
 
-// request sends a request to SLS.
-func request(project *LogProject, method, uri string, headers map[string]string,
+// defaultHTTPClient is used by the projects without their own HTTPClient.
+// Unlike http.DefaultClient, it never waits forever on a dead connection.
+var defaultHTTPClient = &http.Client{
+	Timeout: defaultRequestTimeout,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   defaultDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   defaultTLSHandshakeTimeout,
+		ResponseHeaderTimeout: defaultResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+}
+
+// parseEndpoint returns the scheme and host[:port] of an endpoint, which is
+// either a URL like "http://10.0.0.1:8080" or a bare host using https.
+func parseEndpoint(endpoint string) (scheme, host string, err error) {
+	if !strings.Contains(endpoint, "://") {
+		return "https", strings.TrimRight(endpoint, "/"), nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", "", fmt.Errorf("Invalid endpoint %q", endpoint)
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// request sends a request of operation op, e.g. "PutLogs", to SLS.
+// If ctx is done before the response arrives, ctx.Err() is returned,
+// other failures are returned as an *Error of the operation.
+// Failed attempts are retried according to the project's RetryPolicy,
+// each one with a fresh date, credentials and signature.
+func request(ctx context.Context, project *LogProject, op, method, uri string, headers map[string]string,
 	body []byte) (*http.Response, error) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, project, op, uri, headers, body)
+	req := &Request{
+		Project:   project.Name,
+		Operation: op,
+		Method:    method,
+		URI:       uri,
+		Headers:   headers,
+		Body:      body,
+	}
+	resp, err := doRequest(ctx, project, req)
+	if err != nil {
+		endpoint := req.Endpoint
+		if endpoint == "" {
+			endpoint = project.Endpoint
+		}
+		err = requestError(err, op, endpoint)
+	}
+	if span != nil {
+		endSpan(span, resp, req.Attempt, err)
+	}
+	if project.Metrics != nil {
+		m := RequestMetric{
+			Project:       project.Name,
+			LogStore:      logStoreOf(uri),
+			Operation:     op,
+			Code:          errorCode(err),
+			Attempts:      req.Attempt,
+			Duration:      time.Since(start),
+			RequestBytes:  int64(len(body)),
+			ResponseBytes: -1,
+		}
+		if resp != nil {
+			m.ResponseBytes = resp.ContentLength
+		}
+		project.Metrics.ObserveRequest(m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// requestError returns err, the failure of operation op sent to endpoint,
+// as an *Error. Context errors are returned as is.
+func requestError(err error, op, endpoint string) error {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+	e, ok := err.(*Error)
+	if ok {
+		// The error may be shared, e.g. returned by an interceptor.
+		copied := *e
+		e = &copied
+	} else {
+		e = &Error{Code: "ClientError", Message: err.Error(), Err: err}
+	}
+	if e.Operation == "" {
+		e.Operation = op
+	}
+	if e.Endpoint == "" {
+		e.Endpoint = endpoint
+	}
+	return e
+}
+
+// opError returns err, a failure of operation op of project p outside of
+// its request, e.g. encoding the request or decoding its response r, as an
+// *Error of op. r is nil if there's no response yet.
+func (p *LogProject) opError(err error, op string, r *http.Response) error {
+	endpoint := p.Endpoint
+	if r != nil && r.Request != nil {
+		// The host of the request is the endpoint, or the project's
+		// subdomain of the endpoint.
+		endpoint = strings.TrimPrefix(r.Request.URL.Host, p.Name+".")
+	}
+	return requestError(err, op, endpoint)
+}
+
+// doRequest sends the attempts of req until one succeeds or isn't retried.
+// req.Attempt is the number of attempts sent and req.Endpoint the endpoint
+// of the last one.
+func doRequest(ctx context.Context, project *LogProject, req *Request) (*http.Response, error) {
+	headers := req.Headers
 
 	// The caller should provide 'x-log-bodyrawsize' header
 	if _, ok := headers["x-log-bodyrawsize"]; !ok {
@@ -23,37 +158,149 @@ func request(project *LogProject, method, uri string, headers map[string]string,
 	}
 
 	// SLS public request headers
-	headers["Host"] = project.Name + "." + project.Endpoint
-	headers["Date"] = nowRFC1123()
 	headers["x-log-apiversion"] = version
-	headers["x-log-signaturemethod"] = signatureMethod
-
-	// Access with token
-	if project.SecurityToken != "" {
-		headers["x-acs-security-token"] = project.SecurityToken
+	if project.SignatureVersion != SignatureV4 {
+		headers["x-log-signaturemethod"] = signatureMethod
 	}
 
-	if body != nil {
-		bodyMD5 := fmt.Sprintf("%X", md5.Sum(body))
-		headers["Content-MD5"] = bodyMD5
+	if req.Body != nil {
 		if _, ok := headers["Content-Type"]; !ok {
 			return nil, fmt.Errorf("Can't find 'Content-Type' header")
 		}
 	}
 
-	// Calc Authorization
-	// Authorization = "SLS <AccessKeyId>:<Signature>"
-	digest, err := signature(project, method, uri, headers)
+	var baseURL, region string
+	handler := chainInterceptors(project.Interceptors, func(ctx context.Context, req *Request) (*http.Response, error) {
+		return send(ctx, project, region, baseURL, req)
+	})
+	idempotent := isIdempotent(ctx, req.Method)
+	failed := "" // Endpoint of the last attempt if it failed because of it
+	for {
+		if project.RateLimiter != nil {
+			if err := project.RateLimiter.wait(ctx, project.Name, req); err != nil {
+				return nil, err
+			}
+		}
+
+		req.Attempt++
+		req.Endpoint = project.endpoint(failed)
+		var err error
+		if baseURL, region, err = project.target(req.Endpoint, headers); err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(ctx, req)
+		if project.RateLimiter != nil {
+			project.RateLimiter.observe(project.Name, req, err)
+		}
+		if project.EndpointPool != nil {
+			project.EndpointPool.report(req.Endpoint, err)
+		}
+		if err == nil {
+			return resp, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		failed = ""
+		if isEndpointFailure(err) {
+			failed = req.Endpoint
+		}
+		delay, retry := project.retryPolicy().Backoff(req.Attempt, idempotent, err)
+		if !retry {
+			return nil, err
+		}
+		if project.logEnabled(ctx, LevelWarn) {
+			project.Logger.Log(ctx, LevelWarn, "sls: retry request",
+				Field{"operation", req.Operation},
+				Field{"attempt", req.Attempt},
+				Field{"endpoint", req.Endpoint},
+				Field{"delay", delay},
+				Field{"error", err})
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// target returns the base URL, i.e. scheme://host, of the requests of
+// project p to endpoint, and the region used by SignatureV4. It sets the
+// headers addressing the project.
+func (p *LogProject) target(endpoint string, headers map[string]string) (baseURL, region string, err error) {
+	scheme, host, err := parseEndpoint(endpoint)
+	if err != nil {
+		return "", "", err
+	}
+	if p.projectInHeader(host) {
+		headers["x-log-project"] = p.Name
+	} else {
+		delete(headers, "x-log-project")
+		host = p.Name + "." + host
+	}
+	headers["Host"] = host
+
+	if p.SignatureVersion == SignatureV4 {
+		if region, err = p.region(host); err != nil {
+			return "", "", err
+		}
+	}
+	return fmt.Sprintf("%v://%v", scheme, host), region, nil
+}
+
+// send signs and sends an attempt of a request to baseURL, i.e.
+// scheme://host of the project. The region is only used by SignatureV4.
+func send(ctx context.Context, project *LogProject, region, baseURL string, r *Request) (*http.Response, error) {
+	method, uri, headers, body := r.Method, r.URI, r.Headers, r.Body
+
+	creds, err := project.credentials(ctx)
 	if err != nil {
 		return nil, err
 	}
-	auth := fmt.Sprintf("SLS %v:%v", project.AccessKeyID, digest)
+
+	// Access with token
+	if creds.SecurityToken != "" {
+		headers["x-acs-security-token"] = creds.SecurityToken
+	} else {
+		delete(headers, "x-acs-security-token")
+	}
+
+	if body != nil {
+		headers["Content-MD5"] = fmt.Sprintf("%X", md5.Sum(body))
+	} else {
+		delete(headers, "Content-MD5")
+	}
+	if project.SignatureVersion == SignatureV4 {
+		sum := sha256.Sum256(body)
+		headers["x-log-content-sha256"] = hex.EncodeToString(sum[:])
+	}
+
+	// Calc Authorization
+	var auth string
+	if project.SignatureVersion == SignatureV4 {
+		auth, err = signatureV4(creds, region, method, uri, headers, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Authorization = "SLS <AccessKeyId>:<Signature>"
+		headers["Date"] = nowRFC1123()
+		digest, err := signature(creds.AccessKeySecret, method, uri, headers)
+		if err != nil {
+			return nil, err
+		}
+		auth = fmt.Sprintf("SLS %v:%v", creds.AccessKeyID, digest)
+	}
 	headers["Authorization"] = auth
 
 	// Initialize http request
 	reader := bytes.NewReader(body)
-	urlStr := fmt.Sprintf("https://%v.%v%v", project.Name, project.Endpoint, uri)
-	req, err := http.NewRequest(method, urlStr, reader)
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+uri, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -61,35 +308,53 @@ func request(project *LogProject, method, uri string, headers map[string]string,
 		req.Header.Add(k, v)
 	}
 
-	if glog.V(1) {
-		dump, e := httputil.DumpRequest(req, true)
-		if e != nil {
-			glog.Info(e)
-		}
-		glog.Infof("HTTP Request:\n%v", string(dump))
+	debug := project.logEnabled(ctx, LevelDebug)
+	if debug {
+		project.Logger.Log(ctx, LevelDebug, "sls: send request",
+			Field{"operation", r.Operation},
+			Field{"attempt", r.Attempt},
+			Field{"method", method},
+			Field{"url", req.URL.String()},
+			Field{"headers", redactHeaders(req.Header)},
+			Field{"body_size", len(body)})
 	}
 
 	// Get ready to do request
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	resp, err := project.httpClient().Do(req)
 	if err != nil {
+		if debug {
+			project.Logger.Log(ctx, LevelDebug, "sls: request failed",
+				Field{"operation", r.Operation},
+				Field{"attempt", r.Attempt},
+				Field{"elapsed", time.Since(start)},
+				Field{"error", err})
+		}
 		return nil, err
 	}
+	if debug {
+		project.Logger.Log(ctx, LevelDebug, "sls: receive response",
+			Field{"operation", r.Operation},
+			Field{"attempt", r.Attempt},
+			Field{"status", resp.StatusCode},
+			Field{"request_id", resp.Header.Get("x-log-requestid")},
+			Field{"headers", redactHeaders(resp.Header)},
+			Field{"elapsed", time.Since(start)})
+	}
 
 	// Parse the sls error from body.
 	if resp.StatusCode != http.StatusOK {
 		err := &Error{}
 		buf, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
 		json.Unmarshal(buf, err)
 		err.RequestID = resp.Header.Get("x-log-requestid")
+		err.HTTPCode = resp.StatusCode
 		return nil, err
 	}
 
-	if glog.V(1) {
-		dump, e := httputil.DumpResponse(resp, true)
-		if e != nil {
-			glog.Info(e)
-		}
-		glog.Infof("HTTP Response:\n%v", string(dump))
+	if err := decompressResponse(resp); err != nil {
+		return nil, clientError(err)
 	}
 	return resp, nil
 }
@@ -0,0 +1,149 @@
+package sls
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// recordTransport records the requests it receives and answers them with
+// an empty 200 response.
+type recordTransport struct {
+	reqs []*http.Request
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.reqs = append(t.reqs, req)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte("{}"))),
+		Request:    req,
+	}, nil
+}
+
+func TestRequestUsesProjectHTTPClient(t *testing.T) {
+	rt := &recordTransport{}
+	p, _ := NewLogProject("test-http-client", "cn-hangzhou.log.aliyuncs.com",
+		"mockAccessKeyID", "mockAccessKeySecret")
+	p.WithHTTPClient(&http.Client{Transport: rt})
+
+	if _, err := p.ListLogStore(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.reqs) != 1 {
+		t.Fatalf("expected 1 request through the custom transport, got %v", len(rt.reqs))
+	}
+	if h := rt.reqs[0].URL.Host; h != "test-http-client.cn-hangzhou.log.aliyuncs.com" {
+		t.Errorf("bad host:%v", h)
+	}
+}
+
+func TestClientPassesHTTPClientToProject(t *testing.T) {
+	rt := &recordTransport{}
+	c := &Client{
+		Endpoint:        "cn-hangzhou.log.aliyuncs.com",
+		AccessKeyID:     "mockAccessKeyID",
+		AccessKeySecret: "mockAccessKeySecret",
+		HTTPClient:      &http.Client{Transport: rt},
+	}
+
+	p, err := c.GetProject("test-http-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.HTTPClient != c.HTTPClient {
+		t.Errorf("project doesn't share the client's HTTPClient")
+	}
+	if len(rt.reqs) != 1 {
+		t.Fatalf("expected 1 request through the custom transport, got %v", len(rt.reqs))
+	}
+}
+
+func TestDefaultHTTPClientHasTimeout(t *testing.T) {
+	p := &LogProject{}
+	if p.httpClient() != defaultHTTPClient {
+		t.Fatal("expected defaultHTTPClient")
+	}
+	if defaultHTTPClient.Timeout <= 0 {
+		t.Errorf("defaultHTTPClient should have a timeout")
+	}
+}
+
+// blockTransport blocks every request until its context is done.
+type blockTransport struct{}
+
+func (blockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestRequestCanceledByContext(t *testing.T) {
+	p, _ := NewLogProject("test-context", "cn-hangzhou.log.aliyuncs.com",
+		"mockAccessKeyID", "mockAccessKeySecret")
+	p.WithHTTPClient(&http.Client{Transport: blockTransport{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.ListLogStoreWithContext(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s := &LogStore{Name: "test-context", project: p}
+	if _, _, err := s.PullLogsWithContext(ctx, 0, "cursor", "", 10); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRequestEndpoints(t *testing.T) {
+	cases := []struct {
+		endpoint        string
+		projectInHeader bool
+		url             string
+		host            string
+		project         string // x-log-project header
+	}{
+		{"cn-hangzhou.log.aliyuncs.com", false,
+			"https://test-endpoint.cn-hangzhou.log.aliyuncs.com/logstores",
+			"test-endpoint.cn-hangzhou.log.aliyuncs.com", ""},
+		{"http://cn-hangzhou-intranet.log.aliyuncs.com", false,
+			"http://test-endpoint.cn-hangzhou-intranet.log.aliyuncs.com/logstores",
+			"test-endpoint.cn-hangzhou-intranet.log.aliyuncs.com", ""},
+		{"http://proxy.local:8080/", true,
+			"http://proxy.local:8080/logstores", "proxy.local:8080", "test-endpoint"},
+		{"http://10.0.0.1:8080", false,
+			"http://10.0.0.1:8080/logstores", "10.0.0.1:8080", "test-endpoint"},
+		{"localhost:8443", false,
+			"https://localhost:8443/logstores", "localhost:8443", "test-endpoint"},
+	}
+	for _, c := range cases {
+		rt := &recordTransport{}
+		p, _ := NewLogProject("test-endpoint", c.endpoint, "mockAccessKeyID", "mockAccessKeySecret")
+		p.WithProjectInHeader(c.projectInHeader)
+		p.WithHTTPClient(&http.Client{Transport: rt})
+		if _, err := p.ListLogStore(); err != nil {
+			t.Fatal(err)
+		}
+
+		req := rt.reqs[0]
+		if u := req.URL.String(); u != c.url {
+			t.Errorf("%v: bad url:%v, expected:%v", c.endpoint, u, c.url)
+		}
+		if req.Host != c.host {
+			t.Errorf("%v: bad host:%v, expected:%v", c.endpoint, req.Host, c.host)
+		}
+		if h := req.Header.Get("x-log-project"); h != c.project {
+			t.Errorf("%v: bad x-log-project:%v, expected:%v", c.endpoint, h, c.project)
+		}
+	}
+
+	p, _ := NewLogProject("test-endpoint", "ftp://10.0.0.1", "mockAccessKeyID", "mockAccessKeySecret")
+	if _, err := p.ListLogStore(); err == nil {
+		t.Errorf("expected an error with an ftp endpoint")
+	}
+}
@@ -0,0 +1,120 @@
+package sls
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy decides whether a failed request is sent again.
+type RetryPolicy interface {
+	// Backoff returns how long to wait before the next attempt of a request
+	// whose attempt-th attempt (starting at 1) failed with err, and false if
+	// the request must not be retried. The idempotent tells whether sending
+	// the request more than once is harmless.
+	Backoff(attempt int, idempotent bool, err error) (time.Duration, bool)
+}
+
+// DefaultRetryableCodes are the SLS error codes of the requests rejected by
+// the server without being processed, so that they're safe to retry
+// whatever the request is.
+var DefaultRetryableCodes = map[string]bool{
+	"ServerBusy":            true,
+	"WriteQuotaExceed":      true,
+	"ShardWriteQuotaExceed": true,
+	"ReadQuotaExceed":       true,
+	"ShardReadQuotaExceed":  true,
+}
+
+// BackoffPolicy is a RetryPolicy with exponential backoff and full jitter.
+//
+// Errors with a code in RetryableCodes, and dial errors, are always
+// retried since the server didn't process the request. Server side errors
+// (5xx) and the other network errors, e.g. connection resets, are retried
+// only for idempotent requests, because the server may have processed them.
+type BackoffPolicy struct {
+	MaxAttempts int           // Max number of attempts, including the first one
+	BaseDelay   time.Duration // Delay cap of the first retry, doubled on each retry
+	MaxDelay    time.Duration // Max delay between two attempts
+
+	// RetryableCodes are the SLS error codes always retried,
+	// nil means DefaultRetryableCodes.
+	RetryableCodes map[string]bool
+}
+
+// DefaultRetryPolicy is used by the projects without their own RetryPolicy.
+var DefaultRetryPolicy RetryPolicy = &BackoffPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// NoRetryPolicy never retries a request.
+var NoRetryPolicy RetryPolicy = &BackoffPolicy{MaxAttempts: 1}
+
+// Backoff implements RetryPolicy.
+func (p *BackoffPolicy) Backoff(attempt int, idempotent bool, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !p.retryable(idempotent, err) {
+		return 0, false
+	}
+
+	delay := p.MaxDelay
+	if shift := uint(attempt - 1); shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		delay = p.BaseDelay << shift
+	}
+	if delay <= 0 {
+		return 0, true
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1)), true
+}
+
+func (p *BackoffPolicy) retryable(idempotent bool, err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+
+	codes := p.RetryableCodes
+	if codes == nil {
+		codes = DefaultRetryableCodes
+	}
+
+	var slsErr *Error
+	if errors.As(err, &slsErr) {
+		if codes[slsErr.Code] {
+			return true
+		}
+		if slsErr.HTTPCode != 0 {
+			return idempotent && slsErr.HTTPCode >= http.StatusInternalServerError
+		}
+	}
+
+	// Dial errors, no byte of the request was sent.
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	// Other transport errors, the request may or may not have reached the server.
+	var urlErr *url.Error
+	return idempotent && errors.As(err, &urlErr)
+}
+
+type idempotentKey struct{}
+
+// withIdempotent marks the requests sent with the returned context as safe
+// to retry, whatever their method is.
+func withIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// isIdempotent tells whether a request can be sent more than once.
+func isIdempotent(ctx context.Context, method string) bool {
+	if v, ok := ctx.Value(idempotentKey{}).(bool); ok {
+		return v
+	}
+	return method != "POST"
+}
@@ -0,0 +1,156 @@
+package sls
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// scriptTransport answers the requests with the errors in its script,
+// then with empty 200 responses.
+type scriptTransport struct {
+	script []*Error
+	reqs   []*http.Request
+}
+
+func (t *scriptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.reqs = append(t.reqs, req)
+	status, body := http.StatusOK, []byte("{}")
+	if len(t.script) > 0 {
+		e := t.script[0]
+		t.script = t.script[1:]
+		status = e.HTTPCode
+		body = []byte(e.String())
+	}
+	return &http.Response{
+		StatusCode:    status,
+		Header:        make(http.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func newRetryTestProject(rt http.RoundTripper) *LogProject {
+	p, _ := NewLogProject("test-retry", "cn-hangzhou.log.aliyuncs.com",
+		"mockAccessKeyID", "mockAccessKeySecret")
+	p.WithHTTPClient(&http.Client{Transport: rt})
+	p.WithRetryPolicy(&BackoffPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	return p
+}
+
+func TestRetryThrottledRequest(t *testing.T) {
+	rt := &scriptTransport{script: []*Error{
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+		{Code: "WriteQuotaExceed", HTTPCode: http.StatusForbidden},
+	}}
+	p := newRetryTestProject(rt)
+	if err := p.CreateLogStore("test-retry", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.reqs) != 3 {
+		t.Fatalf("expected 3 attempts, got %v", len(rt.reqs))
+	}
+	for _, req := range rt.reqs {
+		if req.Header.Get("Date") == "" || req.Header.Get("Authorization") == "" {
+			t.Errorf("attempt isn't signed: %v", req.Header)
+		}
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	rt := &scriptTransport{script: []*Error{
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+	}}
+	p := newRetryTestProject(rt)
+	if _, err := p.ListLogStore(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(rt.reqs) != 3 {
+		t.Fatalf("expected 3 attempts, got %v", len(rt.reqs))
+	}
+}
+
+func TestRetryServerErrorOnlyIfIdempotent(t *testing.T) {
+	internalErr := &Error{Code: "InternalServerError", HTTPCode: http.StatusInternalServerError}
+
+	// POST /logstores isn't idempotent, it's sent once.
+	rt := &scriptTransport{script: []*Error{internalErr}}
+	p := newRetryTestProject(rt)
+	if err := p.CreateLogStore("test-retry", 1, 1); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(rt.reqs) != 1 {
+		t.Errorf("expected 1 attempt, got %v", len(rt.reqs))
+	}
+
+	// PutLogs may have stored the logs, it isn't retried either.
+	rt = &scriptTransport{script: []*Error{internalErr}}
+	s := &LogStore{Name: "test-retry", project: newRetryTestProject(rt)}
+	lg := &LogGroup{
+		Logs: []*Log{{
+			Time: proto.Uint32(uint32(time.Now().Unix())),
+			Contents: []*LogContent{{
+				Key:   proto.String("key"),
+				Value: proto.String("value"),
+			}},
+		}},
+	}
+	if err := s.PutLogs(lg); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(rt.reqs) != 1 {
+		t.Errorf("expected 1 attempt, got %v", len(rt.reqs))
+	}
+
+	// But it's retried when the server rejected it without storing it.
+	rt = &scriptTransport{script: []*Error{{Code: "WriteQuotaExceed", HTTPCode: http.StatusForbidden}}}
+	s = &LogStore{Name: "test-retry", project: newRetryTestProject(rt)}
+	if err := s.PutLogs(lg); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.reqs) != 2 {
+		t.Errorf("expected 2 attempts, got %v", len(rt.reqs))
+	}
+}
+
+func TestBackoffPolicy(t *testing.T) {
+	p := &BackoffPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	busy := &Error{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable}
+	for attempt, max := range []time.Duration{100, 200, 300, 300} {
+		d, ok := p.Backoff(attempt+1, false, busy)
+		if !ok {
+			t.Fatalf("attempt %v should be retried", attempt+1)
+		}
+		if d < 0 || d > max*time.Millisecond {
+			t.Errorf("attempt %v: delay %v out of [0, %v]", attempt+1, d, max*time.Millisecond)
+		}
+	}
+	if _, ok := p.Backoff(5, false, busy); ok {
+		t.Error("attempt 5 shouldn't be retried")
+	}
+
+	netErr := &url.Error{Op: "Post", URL: "https://example.com", Err: errors.New("connection reset by peer")}
+	if _, ok := p.Backoff(1, false, netErr); ok {
+		t.Error("network error of a non-idempotent request shouldn't be retried")
+	}
+	if _, ok := p.Backoff(1, true, netErr); !ok {
+		t.Error("network error of an idempotent request should be retried")
+	}
+	dialErr := &url.Error{Op: "Post", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	if _, ok := p.Backoff(1, false, dialErr); !ok {
+		t.Error("dial error of a non-idempotent request should be retried")
+	}
+	if _, ok := p.Backoff(1, true, &Error{Code: "LogStoreNotExist", HTTPCode: http.StatusNotFound}); ok {
+		t.Error("client error shouldn't be retried")
+	}
+}
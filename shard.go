@@ -0,0 +1,79 @@
+package sls
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+)
+
+// Shard statuses.
+const (
+	ShardStatusReadWrite = "readwrite"
+	ShardStatusReadOnly  = "readonly" // The shards split or merged into other shards
+)
+
+// ShardInfo describes a shard of a logstore. A shard holds the logs put
+// with the hash keys in [InclusiveBeginKey, ExclusiveEndKey), the keys are
+// 128 bits in hex, and the read-write shards of a logstore split the key
+// space. A shard split or merged becomes read-only: its logs can still be
+// pulled, the new logs of its keys go to the shards replacing it.
+type ShardInfo struct {
+	ShardID           int    `json:"shardID"`
+	Status            string `json:"status"`
+	InclusiveBeginKey string `json:"inclusiveBeginKey"`
+	ExclusiveEndKey   string `json:"exclusiveEndKey"`
+	CreateTime        uint32 `json:"createTime"`
+}
+
+// ReadWrite tells whether shard sh accepts new logs.
+func (sh *ShardInfo) ReadWrite() bool {
+	return sh.Status != ShardStatusReadOnly
+}
+
+// maxHashKey is the last key of the key space, it belongs to the last shard
+// although its ExclusiveEndKey is maxHashKey too.
+const maxHashKey = "ffffffffffffffffffffffffffffffff"
+
+// HashKey returns the hash key of key, the MD5 of key in hex, to put the
+// logs with the same key into the same shard with PutLogsWithHashKey.
+func HashKey(key string) string {
+	sum := md5.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// isHashKey tells whether hashKey is 128 bits in hex.
+func isHashKey(hashKey string) bool {
+	if len(hashKey) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(hashKey)
+	return err == nil
+}
+
+// Contains tells whether hashKey is in the key range of shard sh.
+func (sh *ShardInfo) Contains(hashKey string) bool {
+	hashKey = strings.ToLower(hashKey)
+	begin, end := strings.ToLower(sh.InclusiveBeginKey), strings.ToLower(sh.ExclusiveEndKey)
+	return begin <= hashKey && (hashKey < end || end == maxHashKey && hashKey == maxHashKey)
+}
+
+// ShardOfHashKey returns the ID of the read-write shard holding hashKey,
+// e.g. the shard PutLogsWithHashKey puts the logs with hashKey into. The
+// shards are the ones returned by ListShardInfos. It returns false if no
+// shard holds hashKey or if hashKey is invalid.
+func ShardOfHashKey(shards []*ShardInfo, hashKey string) (shardID int, ok bool) {
+	if !isHashKey(hashKey) {
+		return 0, false
+	}
+	for _, sh := range shards {
+		if sh.ReadWrite() && sh.Contains(hashKey) {
+			return sh.ShardID, true
+		}
+	}
+	return 0, false
+}
+
+// ShardOfKey is like ShardOfHashKey for the hash key of key, see HashKey.
+func ShardOfKey(shards []*ShardInfo, key string) (shardID int, ok bool) {
+	return ShardOfHashKey(shards, HashKey(key))
+}
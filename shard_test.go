@@ -0,0 +1,157 @@
+package sls_test
+
+import (
+	"fmt"
+	"testing"
+
+	sls "github.com/galaxydi/go-loghub"
+	"github.com/galaxydi/go-loghub/slstest"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestHashKey(t *testing.T) {
+	if k := sls.HashKey(""); k != "d41d8cd98f00b204e9800998ecf8427e" {
+		t.Errorf("unexpected hash key %v", k)
+	}
+
+	shards := []*sls.ShardInfo{
+		{ShardID: 0, InclusiveBeginKey: "00000000000000000000000000000000", ExclusiveEndKey: "80000000000000000000000000000000"},
+		{ShardID: 1, InclusiveBeginKey: "80000000000000000000000000000000", ExclusiveEndKey: "ffffffffffffffffffffffffffffffff"},
+	}
+	for hashKey, expected := range map[string]int{
+		"00000000000000000000000000000000": 0,
+		"7fffffffffffffffffffffffffffffff": 0,
+		"80000000000000000000000000000000": 1,
+		"D41D8CD98F00B204E9800998ECF8427E": 1,
+		"ffffffffffffffffffffffffffffffff": 1,
+		"not a hash key":                   -1,
+		"8000000000000000000000000000000g": -1,
+	} {
+		shardID, ok := sls.ShardOfHashKey(shards, hashKey)
+		if !ok {
+			shardID = -1
+		}
+		if shardID != expected {
+			t.Errorf("ShardOfHashKey(%v) = %v, expected %v", hashKey, shardID, expected)
+		}
+	}
+}
+
+func TestShard(t *testing.T) {
+	suite.Run(t, new(ShardTestSuite))
+}
+
+type ShardTestSuite struct {
+	suite.Suite
+	store *sls.LogStore
+}
+
+func (s *ShardTestSuite) SetupTest() {
+	_, s.store = slstest.NewLogStore(s.T(), 4)
+}
+
+// pulledKeys returns the values of the "key" contents of the logs of a shard.
+func (s *ShardTestSuite) pulledKeys(shardID int) []string {
+	var keys []string
+	for _, lg := range slstest.PullLogs(s.T(), s.store, shardID) {
+		for _, l := range lg.Logs {
+			keys = append(keys, l.Contents[0].GetValue())
+		}
+	}
+	return keys
+}
+
+func (s *ShardTestSuite) TestPutLogsWithHashKey() {
+	shards, err := s.store.ListShardInfos()
+	s.Nil(err)
+	s.Len(shards, 4)
+
+	expected := make(map[int][]string)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("user-%v", i%5)
+		lg := &sls.LogGroup{Logs: []*sls.Log{{
+			Time:     proto.Uint32(1500000000),
+			Contents: []*sls.LogContent{{Key: proto.String("key"), Value: proto.String(key)}},
+		}}}
+		s.Nil(s.store.PutLogsWithHashKey(sls.HashKey(key), lg))
+
+		shardID, ok := sls.ShardOfKey(shards, key)
+		s.True(ok)
+		expected[shardID] = append(expected[shardID], key)
+	}
+
+	for _, sh := range shards {
+		s.Equal(expected[sh.ShardID], s.pulledKeys(sh.ShardID), "shard %v", sh.ShardID)
+	}
+}
+
+func (s *ShardTestSuite) TestPutLogsWithInvalidHashKey() {
+	lg := &sls.LogGroup{Logs: []*sls.Log{{
+		Time:     proto.Uint32(1500000000),
+		Contents: []*sls.LogContent{{Key: proto.String("key"), Value: proto.String("value")}},
+	}}}
+	err := s.store.PutLogsWithHashKey("user-0", lg)
+	slsErr, ok := err.(*sls.Error)
+	s.True(ok)
+	if ok {
+		s.Equal("ClientError", slsErr.Code)
+	}
+}
+
+func (s *ShardTestSuite) TestSplitAndMergeShards() {
+	shards, err := s.store.SplitShard(0, "20000000000000000000000000000000")
+	s.Nil(err)
+	s.Len(shards, 3)
+	for _, sh := range shards {
+		switch sh.ShardID {
+		case 0:
+			s.Equal(sls.ShardStatusReadOnly, sh.Status)
+		case 4:
+			s.Equal("00000000000000000000000000000000", sh.InclusiveBeginKey)
+			s.Equal("20000000000000000000000000000000", sh.ExclusiveEndKey)
+			s.True(sh.ReadWrite())
+		case 5:
+			s.Equal("20000000000000000000000000000000", sh.InclusiveBeginKey)
+			s.Equal("40000000000000000000000000000000", sh.ExclusiveEndKey)
+			s.True(sh.ReadWrite())
+		default:
+			s.True(false, "unexpected shard %v", sh.ShardID)
+		}
+	}
+
+	_, err = s.store.SplitShard(0, "10000000000000000000000000000000")
+	s.True(err != nil, "a read-only shard can't be split")
+	_, err = s.store.SplitShard(1, "10000000000000000000000000000000")
+	s.True(err != nil, "the split key must be in the shard")
+
+	shards, err = s.store.MergeShards(4)
+	s.Nil(err)
+	s.Len(shards, 3)
+	s.Equal(6, shards[0].ShardID)
+	s.Equal("00000000000000000000000000000000", shards[0].InclusiveBeginKey)
+	s.Equal("40000000000000000000000000000000", shards[0].ExclusiveEndKey)
+
+	shards, err = s.store.ListShardInfos()
+	s.Nil(err)
+	s.Len(shards, 7)
+	var readWrite int
+	for _, sh := range shards {
+		if sh.ReadWrite() {
+			readWrite++
+		}
+	}
+	s.Equal(4, readWrite)
+
+	// The logs of the keys of the merged shards go to the new shard.
+	hashKey := "10000000000000000000000000000000"
+	shardID, ok := sls.ShardOfHashKey(shards, hashKey)
+	s.True(ok)
+	s.Equal(6, shardID)
+	lg := &sls.LogGroup{Logs: []*sls.Log{{
+		Time:     proto.Uint32(1500000000),
+		Contents: []*sls.LogContent{{Key: proto.String("key"), Value: proto.String("value")}},
+	}}}
+	s.Nil(s.store.PutLogsWithHashKey(hashKey, lg))
+	s.Equal([]string{"value"}, s.pulledKeys(6))
+}
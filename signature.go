@@ -20,11 +20,31 @@ func nowRFC1123() string {
 	return time.Now().In(gmtLoc).Format(time.RFC1123)
 }
 
+// isSLSHeader tells whether lower-cased header name is an SLS header.
+func isSLSHeader(name string) bool {
+	return strings.HasPrefix(name, "x-log-") || strings.HasPrefix(name, "x-acs-")
+}
+
+// canonicalizeHeaders returns the lower-cased names, sorted, and the
+// trimmed values of the headers whose lower-cased name is signed.
+func canonicalizeHeaders(headers map[string]string,
+	signed func(name string) bool) (names []string, values map[string]string) {
+	values = make(map[string]string, len(headers))
+	for k, v := range headers {
+		l := strings.TrimSpace(strings.ToLower(k))
+		if signed(l) {
+			values[l] = strings.TrimSpace(v)
+			names = append(names, l)
+		}
+	}
+	sort.Strings(names)
+	return names, values
+}
+
 // signature calculates a request's signature digest.
-func signature(project *LogProject, method, uri string,
+func signature(accessKeySecret string, method, uri string,
 	headers map[string]string) (digest string, err error) {
 	var contentMD5, contentType, date, canoHeaders, canoResource string
-	var slsHeaderKeys sort.StringSlice
 
 	if val, ok := headers["Content-MD5"]; ok {
 		contentMD5 = val
@@ -41,16 +61,7 @@ func signature(project *LogProject, method, uri string,
 	}
 
 	// Calc CanonicalizedSLSHeaders
-	slsHeaders := make(map[string]string, len(headers))
-	for k, v := range headers {
-		l := strings.TrimSpace(strings.ToLower(k))
-		if strings.HasPrefix(l, "x-log-") || strings.HasPrefix(l, "x-acs-") {
-			slsHeaders[l] = strings.TrimSpace(v)
-			slsHeaderKeys = append(slsHeaderKeys, l)
-		}
-	}
-
-	sort.Sort(slsHeaderKeys)
+	slsHeaderKeys, slsHeaders := canonicalizeHeaders(headers, isSLSHeader)
 	for i, k := range slsHeaderKeys {
 		canoHeaders += k + ":" + slsHeaders[k]
 		if i+1 < len(slsHeaderKeys) {
@@ -94,7 +105,7 @@ func signature(project *LogProject, method, uri string,
 		canoResource
 
 	// Signature = base64(hmac-sha1(UTF8-Encoding-Of(SignString)，AccessKeySecret))
-	mac := hmac.New(sha1.New, []byte(project.AccessKeySecret))
+	mac := hmac.New(sha1.New, []byte(accessKeySecret))
 	_, err = mac.Write([]byte(signStr))
 	if err != nil {
 		return
@@ -4,9 +4,9 @@ import (
 	"crypto/md5"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
-	"github.com/golang/glog"
 )
 
 var project = &LogProject{
@@ -17,7 +17,6 @@ var project = &LogProject{
 }
 
 func TestSignatureGet(t *testing.T) {
-	defer glog.Flush()
 	h := map[string]string{
 		"x-log-apiversion":      "0.6.0",
 		"x-log-signaturemethod": "hmac-sha1",
@@ -25,7 +24,7 @@ func TestSignatureGet(t *testing.T) {
 		"Date":                  "Mon, 3 Jan 2010 08:33:47 GMT",
 	}
 	digest := "Rwm6cTKzoti4HWoe+GKcb6Kv07E="
-	s, err := signature(project, "GET", "/logstores", h)
+	s, err := signature(project.AccessKeySecret, "GET", "/logstores", h)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -35,8 +34,6 @@ func TestSignatureGet(t *testing.T) {
 }
 
 func TestSignaturePost(t *testing.T) {
-	defer glog.Flush()
-
 	/*
 	   topic=""
 	   time=1405409656
@@ -86,7 +83,7 @@ func TestSignaturePost(t *testing.T) {
 	}
 
 	digest := "87xQWqFaOSewqRIma8kPjGYlXHc="
-	s, err := signature(project, "GET", "/logstores/app_log", h)
+	s, err := signature(project.AccessKeySecret, "GET", "/logstores/app_log", h)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -94,3 +91,103 @@ func TestSignaturePost(t *testing.T) {
 		t.Errorf("Bad digest:%v, expected:%v", s, digest)
 	}
 }
+
+// The V4 signatures below aren't reference signatures: they're computed by
+// this implementation and only catch its changes. The canonical request
+// they sign is checked against the V4 specification by
+// TestCanonicalRequestV4.
+var v4Time = time.Date(2010, 1, 3, 8, 33, 47, 0, time.UTC)
+
+func TestCanonicalRequestV4(t *testing.T) {
+	h := map[string]string{
+		"Host":                 "test-signature.cn-hangzhou.log.aliyuncs.com",
+		"x-log-apiversion":     "0.6.0",
+		"x-log-bodyrawsize":    "0",
+		"x-log-content-sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"x-log-date":           "20100103T083347Z",
+		"x-acs-security-token": "mockToken",
+		"Accept":               "application/json", // not signed
+	}
+	expected := "GET\n" +
+		"/logstores\n" +
+		"logstoreName=app%20log%2A&offset=0&size=100\n" +
+		"host:test-signature.cn-hangzhou.log.aliyuncs.com\n" +
+		"x-acs-security-token:mockToken\n" +
+		"x-log-apiversion:0.6.0\n" +
+		"x-log-bodyrawsize:0\n" +
+		"x-log-content-sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n" +
+		"x-log-date:20100103T083347Z\n" +
+		"\n" +
+		"host;x-acs-security-token;x-log-apiversion;x-log-bodyrawsize;x-log-content-sha256;x-log-date\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s, err := canonicalRequestV4("GET", "/logstores?offset=0&size=100&logstoreName=app%20log%2A", h,
+		h["x-log-content-sha256"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Errorf("Bad canonical request:%q, expected:%q", s, expected)
+	}
+}
+
+func TestSignatureV4Get(t *testing.T) {
+	h := map[string]string{
+		"Host":                 "test-signature.cn-hangzhou.log.aliyuncs.com",
+		"x-log-apiversion":     "0.6.0",
+		"x-log-bodyrawsize":    "0",
+		"x-log-content-sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+	creds := Credentials{AccessKeyID: "mockAccessKeyID", AccessKeySecret: "mockAccessKeySecret"}
+	auth := "SLS4-HMAC-SHA256 Credential=mockAccessKeyID/20100103/cn-hangzhou/sls/aliyun_v4_request," +
+		"Signature=a2edd54f9cfe8c046b9f506ee665afa7c8cc1da39d4d2066e6629bc73c87670f"
+	s, err := signatureV4(creds, "cn-hangzhou", "GET", "/logstores?offset=0&size=100&logstoreName=app%20log%2A", h, v4Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != auth {
+		t.Errorf("Bad authorization:%v, expected:%v", s, auth)
+	}
+	if h["x-log-date"] != "20100103T083347Z" {
+		t.Errorf("Bad x-log-date:%v", h["x-log-date"])
+	}
+}
+
+func TestSignatureV4Post(t *testing.T) {
+	body := []byte(`{"logstoreName":"app_log","ttl":7}`)
+	h := map[string]string{
+		"Host":                 "test-signature.cn-hangzhou.log.aliyuncs.com",
+		"x-log-apiversion":     "0.6.0",
+		"x-log-bodyrawsize":    fmt.Sprintf("%v", len(body)),
+		"x-log-content-sha256": "1178bac5d8142dc23ec9a71933de16380979fd36efd91b564406dcdb6a4a5255",
+		"x-acs-security-token": "mockToken",
+		"Content-Type":         "application/json",
+		"Content-MD5":          fmt.Sprintf("%X", md5.Sum(body)),
+		"Content-Length":       fmt.Sprintf("%v", len(body)),
+	}
+	creds := Credentials{AccessKeyID: "mockAccessKeyID", AccessKeySecret: "mockAccessKeySecret"}
+	auth := "SLS4-HMAC-SHA256 Credential=mockAccessKeyID/20100103/cn-hangzhou/sls/aliyun_v4_request," +
+		"Signature=b08b0a1f4a981720c030ba8fc32d574e72cab1c4a5551cdbe163977c98b323e3"
+	s, err := signatureV4(creds, "cn-hangzhou", "POST", "/logstores", h, v4Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != auth {
+		t.Errorf("Bad authorization:%v, expected:%v", s, auth)
+	}
+}
+
+func TestRegionOf(t *testing.T) {
+	for host, region := range map[string]string{
+		"cn-hangzhou.log.aliyuncs.com":           "cn-hangzhou",
+		"cn-hangzhou-intranet.log.aliyuncs.com":  "cn-hangzhou",
+		"cn-shanghai-share.log.aliyuncs.com:443": "cn-shanghai",
+		"ap-southeast-1-vpc.log.aliyuncs.com":    "ap-southeast-1",
+	} {
+		if r, err := regionOf(host); err != nil || r != region {
+			t.Errorf("Bad region of %v:%v, %v", host, r, err)
+		}
+	}
+	if _, err := regionOf("10.0.0.1:8080"); err == nil {
+		t.Errorf("Expected an error without region")
+	}
+}
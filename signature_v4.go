@@ -0,0 +1,141 @@
+package sls
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SignatureVersion selects how requests are signed.
+type SignatureVersion string
+
+// Signature versions supported by SLS.
+//
+// SignatureV4 is experimental: it follows the V4 specification but hasn't
+// been checked against published signatures or the service yet.
+const (
+	SignatureV1 SignatureVersion = "v1" // hmac-sha1 of the request, the default
+	SignatureV4 SignatureVersion = "v4" // hmac-sha256 with a region scoped key, experimental
+)
+
+const (
+	v4Algorithm    = "SLS4-HMAC-SHA256"
+	v4Service      = "sls"
+	v4Request      = "aliyun_v4_request"
+	v4SecretPrefix = "aliyun_v4"
+	v4DateFormat   = "20060102T150405Z"
+)
+
+// isV4SignedHeader tells whether lower-cased header name is signed by V4.
+func isV4SignedHeader(name string) bool {
+	return name == "host" || name == "content-type" || name == "content-md5" || isSLSHeader(name)
+}
+
+// signatureV4 calculates the 'Authorization' header of a request signed
+// with V4 at time t. The headers must contain 'x-log-content-sha256', the
+// hex SHA256 of the body; 'x-log-date' is set to t.
+//
+// Authorization = "SLS4-HMAC-SHA256 Credential=<AccessKeyId>/<Scope>,Signature=<Signature>"
+func signatureV4(creds Credentials, region, method, uri string, headers map[string]string,
+	t time.Time) (auth string, err error) {
+	contentSHA256, ok := headers["x-log-content-sha256"]
+	if !ok {
+		return "", fmt.Errorf("Can't find 'x-log-content-sha256' header")
+	}
+	dateTime := t.UTC().Format(v4DateFormat)
+	date := dateTime[:8]
+	headers["x-log-date"] = dateTime
+
+	canoRequest, err := canonicalRequestV4(method, uri, headers, contentSHA256)
+	if err != nil {
+		return "", err
+	}
+
+	// StringToSign = Algorithm + "\n" + DateTime + "\n" + Scope + "\n" + hex(sha256(CanonicalRequest))
+	scope := date + "/" + region + "/" + v4Service + "/" + v4Request
+	hash := sha256.Sum256([]byte(canoRequest))
+	signStr := v4Algorithm + "\n" +
+		dateTime + "\n" +
+		scope + "\n" +
+		hex.EncodeToString(hash[:])
+
+	// SigningKey = hmac(hmac(hmac(hmac("aliyun_v4" + AccessKeySecret, Date), Region), "sls"), "aliyun_v4_request")
+	key := hmacSHA256([]byte(v4SecretPrefix+creds.AccessKeySecret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, v4Service)
+	key = hmacSHA256(key, v4Request)
+	digest := hex.EncodeToString(hmacSHA256(key, signStr))
+
+	return fmt.Sprintf("%v Credential=%v/%v,Signature=%v", v4Algorithm, creds.AccessKeyID, scope, digest), nil
+}
+
+// canonicalRequestV4 returns the canonical request signed by V4:
+//
+//	Method + "\n" + CanonicalURI + "\n" + CanonicalQueryString + "\n" +
+//	CanonicalHeaders + "\n" + SignedHeaders + "\n" + HashedPayload
+func canonicalRequestV4(method, uri string, headers map[string]string, contentSHA256 string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	names, values := canonicalizeHeaders(headers, isV4SignedHeader)
+	var canoHeaders string
+	for _, k := range names {
+		canoHeaders += k + ":" + values[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+	return method + "\n" +
+		u.EscapedPath() + "\n" +
+		canonicalQueryV4(u.Query()) + "\n" +
+		canoHeaders + "\n" +
+		signedHeaders + "\n" +
+		contentSHA256, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQueryV4 returns the query parameters sorted by name, with their
+// names and values percent-encoded.
+func canonicalQueryV4(query url.Values) string {
+	var params []string
+	for k, vals := range query {
+		for _, v := range vals {
+			params = append(params, escapeV4(k)+"="+escapeV4(v))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// escapeV4 percent-encodes s like RFC 3986, leaving only the unreserved
+// characters as is.
+func escapeV4(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// regionOf returns the region of an SLS endpoint host, e.g. "cn-hangzhou"
+// for "cn-hangzhou-intranet.log.aliyuncs.com".
+func regionOf(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	i := strings.Index(host, ".log.aliyuncs.com")
+	if i <= 0 {
+		return "", fmt.Errorf("Can't find the region of endpoint %v, set the Region of the project", host)
+	}
+	region := host[:i]
+	for _, suffix := range []string{"-intranet", "-vpc", "-share"} {
+		region = strings.TrimSuffix(region, suffix)
+	}
+	return region, nil
+}
@@ -0,0 +1,182 @@
+package slstest
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// jsonObject decodes the JSON object body of a request.
+func (c *call) jsonObject() (map[string]interface{}, *apiError) {
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(c.body, &obj); err != nil {
+		return nil, errorf(http.StatusBadRequest, "PostBodyInvalid", "%v", err)
+	}
+	return obj, nil
+}
+
+// resources are the logtail configs or machine groups of a project.
+type resources struct {
+	items    map[string]map[string]interface{}
+	kind     string // Resource name in URIs, e.g. "configs"
+	nameKey  string // JSON key of the resource name
+	notExist string // Error code of missing resources
+	exist    string // Error code of duplicate resources
+}
+
+func (p *project) configResources() *resources {
+	return &resources{
+		items:    p.configs,
+		kind:     "configs",
+		nameKey:  "configName",
+		notExist: "ConfigNotExist",
+		exist:    "ConfigAlreadyExist",
+	}
+}
+
+func (p *project) machineGroupResources() *resources {
+	return &resources{
+		items:    p.machineGroups,
+		kind:     "machinegroups",
+		nameKey:  "groupName",
+		notExist: "MachineGroupNotExist",
+		exist:    "MachineGroupAlreadyExist",
+	}
+}
+
+// serve serves the requests of /<kind> and /<kind>/<name>.
+func (rs *resources) serve(c *call) *apiError {
+	if len(c.path) == 1 {
+		switch c.r.Method {
+		case "GET":
+			var names []string
+			for name := range rs.items {
+				names = append(names, name)
+			}
+			offset, size := c.page(500)
+			names = pageOf(names, offset, size)
+			return writeJSON(c.w, map[string]interface{}{
+				"count": len(names),
+				"total": len(rs.items),
+				rs.kind: names,
+			})
+		case "POST":
+			obj, e := c.jsonObject()
+			if e != nil {
+				return e
+			}
+			name, _ := obj[rs.nameKey].(string)
+			if name == "" {
+				return errorf(http.StatusBadRequest, "PostBodyInvalid", "missing %v", rs.nameKey)
+			}
+			if _, ok := rs.items[name]; ok {
+				return errorf(http.StatusBadRequest, rs.exist, "%v already exist", name)
+			}
+			obj["createTime"] = now()
+			obj["lastModifyTime"] = now()
+			rs.items[name] = obj
+			return nil
+		}
+		return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%v /%v", c.r.Method, rs.kind)
+	}
+
+	name := c.path[1]
+	item, ok := rs.items[name]
+	if !ok {
+		return errorf(http.StatusNotFound, rs.notExist, "%v does not exist", name)
+	}
+
+	switch c.r.Method {
+	case "GET":
+		return writeJSON(c.w, item)
+	case "PUT":
+		obj, e := c.jsonObject()
+		if e != nil {
+			return e
+		}
+		obj[rs.nameKey] = name
+		obj["createTime"] = item["createTime"]
+		obj["lastModifyTime"] = now()
+		rs.items[name] = obj
+		return nil
+	case "DELETE":
+		delete(rs.items, name)
+		return nil
+	}
+	return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%v /%v/%v", c.r.Method, rs.kind, name)
+}
+
+// serveConfigs serves the requests of /configs resources.
+func (p *project) serveConfigs(c *call) *apiError {
+	if len(c.path) == 3 && c.path[2] == "machinegroups" && c.r.Method == "GET" {
+		if _, ok := p.configs[c.path[1]]; !ok {
+			return errorf(http.StatusNotFound, "ConfigNotExist", "%v does not exist", c.path[1])
+		}
+		var groups []string
+		for group, configs := range p.applied {
+			if configs[c.path[1]] {
+				groups = append(groups, group)
+			}
+		}
+		groups = pageOf(groups, 0, len(groups))
+		return writeJSON(c.w, map[string]interface{}{
+			"count":         len(groups),
+			"machinegroups": groups,
+		})
+	}
+	if len(c.path) > 2 {
+		return errorf(http.StatusNotFound, "InvalidURI", "unknown resource: %v", c.r.URL.Path)
+	}
+	return p.configResources().serve(c)
+}
+
+// serveMachineGroups serves the requests of /machinegroups resources.
+func (p *project) serveMachineGroups(c *call) *apiError {
+	if len(c.path) <= 2 {
+		e := p.machineGroupResources().serve(c)
+		if e == nil && c.r.Method == "DELETE" {
+			delete(p.applied, c.path[1])
+		}
+		return e
+	}
+
+	group := c.path[1]
+	if _, ok := p.machineGroups[group]; !ok {
+		return errorf(http.StatusNotFound, "MachineGroupNotExist", "%v does not exist", group)
+	}
+
+	switch {
+	case len(c.path) == 3 && c.path[2] == "machines" && c.r.Method == "GET":
+		return writeJSON(c.w, map[string]interface{}{
+			"count":    0,
+			"total":    0,
+			"machines": []interface{}{},
+		})
+	case len(c.path) == 3 && c.path[2] == "configs" && c.r.Method == "GET":
+		var configs []string
+		for config := range p.applied[group] {
+			configs = append(configs, config)
+		}
+		configs = pageOf(configs, 0, len(configs))
+		return writeJSON(c.w, map[string]interface{}{
+			"count":   len(configs),
+			"configs": configs,
+		})
+	case len(c.path) == 4 && c.path[2] == "configs":
+		config := c.path[3]
+		if _, ok := p.configs[config]; !ok {
+			return errorf(http.StatusNotFound, "ConfigNotExist", "%v does not exist", config)
+		}
+		switch c.r.Method {
+		case "PUT":
+			if p.applied[group] == nil {
+				p.applied[group] = make(map[string]bool)
+			}
+			p.applied[group][config] = true
+			return nil
+		case "DELETE":
+			delete(p.applied[group], config)
+			return nil
+		}
+	}
+	return errorf(http.StatusNotFound, "InvalidURI", "unknown resource: %v", c.r.URL.Path)
+}
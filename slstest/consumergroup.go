@@ -0,0 +1,194 @@
+package slstest
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	sls "github.com/galaxydi/go-loghub"
+)
+
+// consumerGroup is a consumer group of a logstore.
+type consumerGroup struct {
+	sls.ConsumerGroup
+
+	heartbeats  map[string]time.Time    // Last heartbeat of the consumers
+	held        map[string]map[int]bool // Shards held by the consumers
+	owners      map[int]string          // Consumers the shards are assigned to
+	checkpoints map[int]*sls.ConsumerGroupCheckpoint
+}
+
+func (s *logstore) serveConsumerGroups(c *call) *apiError {
+	if len(c.path) == 3 {
+		switch c.r.Method {
+		case "GET":
+			var names []string
+			for name := range s.groups {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			groups := []*sls.ConsumerGroup{}
+			for _, name := range names {
+				cg := s.groups[name].ConsumerGroup
+				groups = append(groups, &cg)
+			}
+			return writeJSON(c.w, groups)
+		case "POST":
+			var cg sls.ConsumerGroup
+			if e := c.decodeJSON(&cg); e != nil {
+				return e
+			}
+			if _, ok := s.groups[cg.Name]; ok {
+				return errorf(http.StatusBadRequest, "ConsumerGroupAlreadyExist", "consumer group %v already exist", cg.Name)
+			}
+			s.groups[cg.Name] = &consumerGroup{
+				ConsumerGroup: cg,
+				heartbeats:    make(map[string]time.Time),
+				held:          make(map[string]map[int]bool),
+				owners:        make(map[int]string),
+				checkpoints:   make(map[int]*sls.ConsumerGroupCheckpoint),
+			}
+			return nil
+		}
+		return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%v consumergroups", c.r.Method)
+	}
+
+	cg, ok := s.groups[c.path[3]]
+	if !ok {
+		return errorf(http.StatusNotFound, "ConsumerGroupNotExist", "consumer group %v does not exist", c.path[3])
+	}
+
+	switch c.r.Method {
+	case "GET":
+		checkpoints := []*sls.ConsumerGroupCheckpoint{}
+		for _, sh := range s.shards {
+			cp, ok := cg.checkpoints[sh.id]
+			if !ok {
+				cp = &sls.ConsumerGroupCheckpoint{ShardID: sh.id}
+			}
+			checkpoints = append(checkpoints, cp)
+		}
+		return writeJSON(c.w, checkpoints)
+	case "PUT":
+		var body struct {
+			Timeout int  `json:"timeout"`
+			InOrder bool `json:"order"`
+		}
+		if e := c.decodeJSON(&body); e != nil {
+			return e
+		}
+		cg.Timeout = body.Timeout
+		cg.InOrder = body.InOrder
+		return nil
+	case "DELETE":
+		delete(s.groups, cg.Name)
+		return nil
+	case "POST":
+		switch c.query.Get("type") {
+		case "heartbeat":
+			return s.heartbeat(c, cg)
+		case "checkpoint":
+			return s.updateCheckpoint(c, cg)
+		}
+	}
+	return errorf(http.StatusBadRequest, "ParameterInvalid", "invalid consumer group request")
+}
+
+// heartbeat records the shards held by a consumer and returns the shards
+// assigned to it. The shards are balanced between the live consumers, a
+// shard moves to another consumer once its previous owner released it.
+func (s *logstore) heartbeat(c *call, cg *consumerGroup) *apiError {
+	consumer := c.query.Get("consumer")
+	var held []int
+	if e := c.decodeJSON(&held); e != nil {
+		return e
+	}
+
+	now := time.Now()
+	cg.heartbeats[consumer] = now
+	cg.held[consumer] = make(map[int]bool)
+	for _, id := range held {
+		cg.held[consumer][id] = true
+	}
+
+	// Remove the dead consumers.
+	timeout := time.Duration(cg.Timeout) * time.Second
+	for name, t := range cg.heartbeats {
+		if timeout > 0 && now.Sub(t) > timeout {
+			delete(cg.heartbeats, name)
+			delete(cg.held, name)
+		}
+	}
+	for id, owner := range cg.owners {
+		if _, ok := cg.heartbeats[owner]; !ok {
+			delete(cg.owners, id)
+		}
+	}
+
+	// Balance the shards, at most quota shards per consumer.
+	var consumers []string
+	for name := range cg.heartbeats {
+		consumers = append(consumers, name)
+	}
+	sort.Strings(consumers)
+	quota := (len(s.shards) + len(consumers) - 1) / len(consumers)
+	count := make(map[string]int)
+	for _, sh := range s.shards {
+		if owner, ok := cg.owners[sh.id]; ok {
+			if count[owner] < quota {
+				count[owner]++
+			} else {
+				delete(cg.owners, sh.id)
+			}
+		}
+	}
+	for _, sh := range s.shards {
+		if _, ok := cg.owners[sh.id]; ok {
+			continue
+		}
+		least := consumers[0]
+		for _, name := range consumers {
+			if count[name] < count[least] {
+				least = name
+			}
+		}
+		cg.owners[sh.id] = least
+		count[least]++
+	}
+
+	assigned := []int{}
+	for _, sh := range s.shards {
+		if cg.owners[sh.id] != consumer {
+			continue
+		}
+		free := true
+		for name, shards := range cg.held {
+			if name != consumer && shards[sh.id] {
+				free = false
+			}
+		}
+		if free {
+			assigned = append(assigned, sh.id)
+		}
+	}
+	return writeJSON(c.w, assigned)
+}
+
+func (s *logstore) updateCheckpoint(c *call, cg *consumerGroup) *apiError {
+	consumer := c.query.Get("consumer")
+	var cp sls.ConsumerGroupCheckpoint
+	if e := c.decodeJSON(&cp); e != nil {
+		return e
+	}
+	if cp.ShardID < 0 || cp.ShardID >= len(s.shards) {
+		return errorf(http.StatusNotFound, "ShardNotExist", "shard %v does not exist", cp.ShardID)
+	}
+	if c.query.Get("forceSuccess") != "true" && cg.owners[cp.ShardID] != consumer {
+		return errorf(http.StatusBadRequest, "ConsumerNotMatch", "shard %v isn't assigned to %v", cp.ShardID, consumer)
+	}
+
+	cp.Consumer = consumer
+	cp.UpdateTime = time.Now().UnixNano() / int64(time.Microsecond)
+	cg.checkpoints[cp.ShardID] = &cp
+	return nil
+}
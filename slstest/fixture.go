@@ -0,0 +1,60 @@
+package slstest
+
+import (
+	"testing"
+
+	sls "github.com/galaxydi/go-loghub"
+)
+
+// Names of the project and logstore created by NewLogStore.
+const (
+	ProjectName  = "test-project"
+	LogStoreName = "test-logstore"
+)
+
+// NewLogStore starts a server with the project ProjectName holding the
+// logstore LogStoreName of shardCount shards, and returns the logstore. The
+// server is closed at the end of the test.
+//
+//	srv, store := slstest.NewLogStore(t, 4)
+//	err := store.PutLogs(lg)
+func NewLogStore(t testing.TB, shardCount int) (*Server, *sls.LogStore) {
+	t.Helper()
+	srv := NewServer("mockAccessKeyID", "mockAccessKeySecret")
+	t.Cleanup(srv.Close)
+
+	project, err := srv.NewClient().CreateProject(ProjectName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.CreateLogStore(LogStoreName, 1, shardCount); err != nil {
+		t.Fatal(err)
+	}
+	store, err := project.GetLogStore(LogStoreName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, store
+}
+
+// PullLogs returns the log groups of a shard of logstore s, from the
+// oldest one.
+func PullLogs(t testing.TB, s *sls.LogStore, shardID int) []*sls.LogGroup {
+	t.Helper()
+	cursor, err := s.GetCursor(shardID, sls.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var groups []*sls.LogGroup
+	for {
+		gl, next, err := s.PullLogs(shardID, cursor, "", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(gl.LogGroups) == 0 {
+			return groups
+		}
+		groups = append(groups, gl.LogGroups...)
+		cursor = next
+	}
+}
@@ -0,0 +1,584 @@
+package slstest
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	sls "github.com/galaxydi/go-loghub"
+)
+
+// project is a project stored by the server.
+type project struct {
+	name        string
+	description string
+	createTime  uint32
+
+	logstores     map[string]*logstore
+	configs       map[string]map[string]interface{}
+	machineGroups map[string]map[string]interface{}
+	applied       map[string]map[string]bool // Config names applied to machine groups
+}
+
+func newProject(name, description string) *project {
+	return &project{
+		name:          name,
+		description:   description,
+		createTime:    now(),
+		logstores:     make(map[string]*logstore),
+		configs:       make(map[string]map[string]interface{}),
+		machineGroups: make(map[string]map[string]interface{}),
+		applied:       make(map[string]map[string]bool),
+	}
+}
+
+// logstore is a logstore stored by the server.
+type logstore struct {
+	name           string
+	ttl            int
+	createTime     uint32
+	lastModifyTime uint32
+
+	shards []*shard
+	next   int // Shard receiving the next log group
+	index  *sls.Index
+	groups map[string]*consumerGroup
+}
+
+// shard is a shard of a logstore, holding the log groups written to it.
+type shard struct {
+	id                int
+	inclusiveBeginKey string
+	exclusiveEndKey   string
+	createTime        uint32
+	readOnly          bool // Split or merged into other shards
+
+	groups []*sls.LogGroup
+	times  []uint32 // Receive time of groups
+}
+
+// contains tells whether hashKey, 32 lower case hex digits, is in the key
+// range of shard sh. The last key belongs to the last shard.
+func (sh *shard) contains(hashKey string) bool {
+	if len(hashKey) != 32 {
+		return false
+	}
+	if _, err := hex.DecodeString(hashKey); err != nil {
+		return false
+	}
+	return sh.inclusiveBeginKey <= hashKey &&
+		(hashKey < sh.exclusiveEndKey || hashKey == sh.exclusiveEndKey && strings.Count(hashKey, "f") == 32)
+}
+
+func newLogStore(name string, ttl, shardCount int) *logstore {
+	if shardCount <= 0 {
+		shardCount = 1
+	}
+	s := &logstore{
+		name:           name,
+		ttl:            ttl,
+		createTime:     now(),
+		lastModifyTime: now(),
+		groups:         make(map[string]*consumerGroup),
+	}
+
+	// Shards split the 128 bits MD5 hash key space evenly.
+	space := new(big.Int).Lsh(big.NewInt(1), 128)
+	for i := 0; i < shardCount; i++ {
+		begin := new(big.Int).Div(new(big.Int).Mul(space, big.NewInt(int64(i))), big.NewInt(int64(shardCount)))
+		end := new(big.Int).Div(new(big.Int).Mul(space, big.NewInt(int64(i+1))), big.NewInt(int64(shardCount)))
+		if i == shardCount-1 {
+			end.Sub(end, big.NewInt(1))
+		}
+		s.shards = append(s.shards, &shard{
+			id:                i,
+			inclusiveBeginKey: fmt.Sprintf("%032x", begin),
+			exclusiveEndKey:   fmt.Sprintf("%032x", end),
+			createTime:        now(),
+		})
+	}
+	return s
+}
+
+func (s *logstore) info() map[string]interface{} {
+	return map[string]interface{}{
+		"logstoreName":   s.name,
+		"ttl":            s.ttl,
+		"shardCount":     len(s.shards),
+		"createTime":     s.createTime,
+		"lastModifyTime": s.lastModifyTime,
+	}
+}
+
+// serveLogStores serves the requests of /logstores resources.
+func (p *project) serveLogStores(c *call) *apiError {
+	if len(c.path) == 1 {
+		switch c.r.Method {
+		case "GET":
+			var names []string
+			for name := range p.logstores {
+				names = append(names, name)
+			}
+			offset, size := c.page(500)
+			names = pageOf(names, offset, size)
+			return writeJSON(c.w, map[string]interface{}{
+				"count":     len(names),
+				"total":     len(p.logstores),
+				"logstores": names,
+			})
+		case "POST":
+			var body struct {
+				Name       string `json:"logstoreName"`
+				TTL        int    `json:"ttl"`
+				ShardCount int    `json:"shardCount"`
+			}
+			if e := c.decodeJSON(&body); e != nil {
+				return e
+			}
+			if _, ok := p.logstores[body.Name]; ok {
+				return errorf(http.StatusBadRequest, "LogStoreAlreadyExist", "logstore %v already exist", body.Name)
+			}
+			p.logstores[body.Name] = newLogStore(body.Name, body.TTL, body.ShardCount)
+			return nil
+		}
+		return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%v /logstores", c.r.Method)
+	}
+
+	s, ok := p.logstores[c.path[1]]
+	if !ok {
+		return errorf(http.StatusNotFound, "LogStoreNotExist", "logstore %v does not exist", c.path[1])
+	}
+
+	if len(c.path) == 2 {
+		switch c.r.Method {
+		case "GET":
+			switch c.query.Get("type") {
+			case "log":
+				return s.getLogs(c)
+			case "histogram":
+				return s.getHistograms(c)
+			}
+			return writeJSON(c.w, s.info())
+		case "POST":
+			return s.putLogs(c, nil)
+		case "PUT":
+			var body struct {
+				TTL int `json:"ttl"`
+			}
+			if e := c.decodeJSON(&body); e != nil {
+				return e
+			}
+			s.ttl = body.TTL
+			s.lastModifyTime = now()
+			return nil
+		case "DELETE":
+			delete(p.logstores, s.name)
+			return nil
+		}
+		return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%v /logstores/%v", c.r.Method, s.name)
+	}
+
+	switch c.path[2] {
+	case "shards":
+		return s.serveShards(c)
+	case "index":
+		return s.serveIndex(c)
+	case "consumergroups":
+		return s.serveConsumerGroups(c)
+	}
+	return errorf(http.StatusNotFound, "InvalidURI", "unknown resource: %v", c.r.URL.Path)
+}
+
+func (s *logstore) serveShards(c *call) *apiError {
+	if len(c.path) == 3 {
+		return writeShards(c, s.shards...)
+	}
+
+	if len(c.path) == 4 && c.r.Method == "POST" {
+		switch c.path[3] {
+		case "lb":
+			return s.putLogs(c, nil)
+		case "route":
+			key := strings.ToLower(c.query.Get("key"))
+			for _, sh := range s.shards {
+				if !sh.readOnly && sh.contains(key) {
+					return s.putLogs(c, sh)
+				}
+			}
+			return errorf(http.StatusBadRequest, "ParameterInvalid", "invalid hash key: %v", c.query.Get("key"))
+		}
+	}
+
+	id, err := strconv.Atoi(c.path[3])
+	if err != nil || id < 0 || id >= len(s.shards) {
+		return errorf(http.StatusNotFound, "ShardNotExist", "shard %v does not exist", c.path[3])
+	}
+	sh := s.shards[id]
+
+	if c.r.Method == "POST" {
+		switch c.query.Get("action") {
+		case "split":
+			return s.splitShard(c, sh)
+		case "merge":
+			return s.mergeShards(c, sh)
+		}
+		return errorf(http.StatusBadRequest, "ParameterInvalid", "invalid action: %v", c.query.Get("action"))
+	}
+
+	switch c.query.Get("type") {
+	case "cursor":
+		return sh.getCursor(c)
+	case "logs":
+		return sh.pullLogs(c)
+	}
+	return errorf(http.StatusBadRequest, "ParameterInvalid", "invalid type: %v", c.query.Get("type"))
+}
+
+func writeShards(c *call, shards ...*shard) *apiError {
+	infos := make([]map[string]interface{}, 0, len(shards))
+	for _, sh := range shards {
+		status := "readwrite"
+		if sh.readOnly {
+			status = "readonly"
+		}
+		infos = append(infos, map[string]interface{}{
+			"shardID":           sh.id,
+			"status":            status,
+			"inclusiveBeginKey": sh.inclusiveBeginKey,
+			"exclusiveEndKey":   sh.exclusiveEndKey,
+			"createTime":        sh.createTime,
+		})
+	}
+	return writeJSON(c.w, infos)
+}
+
+// addShard adds a read-write shard holding the keys in [begin, end).
+func (s *logstore) addShard(begin, end string) *shard {
+	sh := &shard{
+		id:                len(s.shards),
+		inclusiveBeginKey: begin,
+		exclusiveEndKey:   end,
+		createTime:        now(),
+	}
+	s.shards = append(s.shards, sh)
+	return sh
+}
+
+// splitShard serves SplitShard, sh is split at the hash key of the query.
+func (s *logstore) splitShard(c *call, sh *shard) *apiError {
+	if sh.readOnly {
+		return errorf(http.StatusBadRequest, "ShardReadOnly", "shard %v is read-only", sh.id)
+	}
+	key := strings.ToLower(c.query.Get("key"))
+	if !sh.contains(key) || key == sh.inclusiveBeginKey {
+		return errorf(http.StatusBadRequest, "ParameterInvalid", "invalid split key: %v", c.query.Get("key"))
+	}
+	sh.readOnly = true
+	first := s.addShard(sh.inclusiveBeginKey, key)
+	second := s.addShard(key, sh.exclusiveEndKey)
+	return writeShards(c, sh, first, second)
+}
+
+// mergeShards serves MergeShards, sh is merged with the read-write shard
+// holding the next keys.
+func (s *logstore) mergeShards(c *call, sh *shard) *apiError {
+	if sh.readOnly {
+		return errorf(http.StatusBadRequest, "ShardReadOnly", "shard %v is read-only", sh.id)
+	}
+	for _, next := range s.shards {
+		if !next.readOnly && next.inclusiveBeginKey == sh.exclusiveEndKey {
+			sh.readOnly, next.readOnly = true, true
+			merged := s.addShard(sh.inclusiveBeginKey, next.exclusiveEndKey)
+			return writeShards(c, merged, sh, next)
+		}
+	}
+	return errorf(http.StatusBadRequest, "ParameterInvalid", "shard %v has no next shard to merge with", sh.id)
+}
+
+func (s *logstore) serveIndex(c *call) *apiError {
+	switch c.r.Method {
+	case "POST":
+		if s.index != nil {
+			return errorf(http.StatusBadRequest, "IndexAlreadyExist", "index of %v already exist", s.name)
+		}
+		fallthrough
+	case "PUT":
+		index := &sls.Index{}
+		if e := c.decodeJSON(index); e != nil {
+			return e
+		}
+		s.index = index
+		return nil
+	case "GET":
+		if s.index == nil {
+			return errorf(http.StatusNotFound, "IndexConfigNotExist", "index of %v does not exist", s.name)
+		}
+		return writeJSON(c.w, s.index)
+	case "DELETE":
+		if s.index == nil {
+			return errorf(http.StatusNotFound, "IndexConfigNotExist", "index of %v does not exist", s.name)
+		}
+		s.index = nil
+		return nil
+	}
+	return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%v index", c.r.Method)
+}
+
+// putLogs serves PutLogs, the log group goes to shard sh, or to the shards
+// in turn if sh is nil.
+func (s *logstore) putLogs(c *call, sh *shard) *apiError {
+	if c.r.Header.Get("Content-Type") != "application/x-protobuf" {
+		return errorf(http.StatusBadRequest, "InvalidContentType", "unexpected content type: %v", c.r.Header.Get("Content-Type"))
+	}
+
+	raw, e := decompress(c)
+	if e != nil {
+		return e
+	}
+	lg := &sls.LogGroup{}
+	if err := lg.Unmarshal(raw); err != nil {
+		return errorf(http.StatusBadRequest, "PostBodyInvalid", "%v", err)
+	}
+	if len(raw) > sls.MaxLogGroupSize || len(lg.Logs) > sls.MaxLogGroupCount {
+		return errorf(http.StatusBadRequest, "PostBodyTooLarge", "log group has %v logs and %v bytes", len(lg.Logs), len(raw))
+	}
+
+	for sh == nil || sh.readOnly {
+		sh = s.shards[s.next%len(s.shards)]
+		s.next++
+	}
+	sh.groups = append(sh.groups, lg)
+	sh.times = append(sh.times, now())
+	return nil
+}
+
+// decompress returns the raw body of a request.
+func decompress(c *call) ([]byte, *apiError) {
+	rawSize, err := strconv.Atoi(c.r.Header.Get("x-log-bodyrawsize"))
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "InvalidBodyRawSize", "%v", err)
+	}
+
+	ct := c.r.Header.Get("x-log-compresstype")
+	if ct == "" {
+		return c.body, nil
+	}
+	codec := sls.GetCompressor(ct)
+	if codec == nil {
+		return nil, errorf(http.StatusBadRequest, "InvalidCompressType", "unsupported compress type: %v", ct)
+	}
+	raw, err := codec.Decompress(c.body, rawSize)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "InvalidCompressType", "%v", err)
+	}
+	return raw, nil
+}
+
+// Cursors are the base64 encoded index of a log group in its shard.
+func encodeCursor(i int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(i)))
+}
+
+func decodeCursor(cursor string) (int, *apiError) {
+	buf, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errorf(http.StatusBadRequest, "InvalidCursor", "invalid cursor: %v", cursor)
+	}
+	i, err := strconv.Atoi(string(buf))
+	if err != nil || i < 0 {
+		return 0, errorf(http.StatusBadRequest, "InvalidCursor", "invalid cursor: %v", cursor)
+	}
+	return i, nil
+}
+
+func (sh *shard) getCursor(c *call) *apiError {
+	var i int
+	switch from := c.query.Get("from"); from {
+	case sls.OffsetOldest:
+		i = 0
+	case sls.OffsetNewest:
+		i = len(sh.groups)
+	default:
+		ts, err := strconv.ParseUint(from, 10, 32)
+		if err != nil {
+			return errorf(http.StatusBadRequest, "ParameterInvalid", "invalid from: %v", from)
+		}
+		i = sort.Search(len(sh.times), func(i int) bool { return sh.times[i] >= uint32(ts) })
+	}
+	return writeJSON(c.w, map[string]string{"cursor": encodeCursor(i)})
+}
+
+// pullLogs serves PullLogs, the log groups are compressed with the
+// compress type in the 'Accept-Encoding' header, if any.
+func (sh *shard) pullLogs(c *call) *apiError {
+	begin, e := decodeCursor(c.query.Get("cursor"))
+	if e != nil {
+		return e
+	}
+	end := len(sh.groups)
+	if endCursor := c.query.Get("end_cursor"); endCursor != "" {
+		if end, e = decodeCursor(endCursor); e != nil {
+			return e
+		}
+	}
+	count, err := strconv.Atoi(c.query.Get("count"))
+	if err != nil || count <= 0 {
+		return errorf(http.StatusBadRequest, "ParameterInvalid", "invalid count: %v", c.query.Get("count"))
+	}
+	if end > len(sh.groups) {
+		end = len(sh.groups)
+	}
+	if begin > end {
+		begin = end
+	}
+	if begin+count < end {
+		end = begin + count
+	}
+
+	gl := &sls.LogGroupList{LogGroups: sh.groups[begin:end]}
+	raw, err := gl.Marshal()
+	if err != nil {
+		return errorf(http.StatusInternalServerError, "InternalServerError", "%v", err)
+	}
+	out := raw
+	h := c.w.Header()
+	if codec := sls.GetCompressor(c.r.Header.Get("Accept-Encoding")); codec != nil && codec.Name() != sls.CompressNone {
+		if out, err = codec.Compress(raw); err != nil {
+			return errorf(http.StatusInternalServerError, "InternalServerError", "%v", err)
+		}
+		h.Set("x-log-compresstype", codec.Name())
+	}
+	h.Set("Content-Type", "application/x-protobuf")
+	h.Set("x-log-bodyrawsize", strconv.Itoa(len(raw)))
+	h.Set("x-log-cursor", encodeCursor(end))
+	h.Set("x-log-count", strconv.Itoa(end-begin))
+	c.w.WriteHeader(http.StatusOK)
+	c.w.Write(out)
+	return nil
+}
+
+// query returns the logs in the [from, to) time range of the request that
+// match its topic and query. A query is a list of terms that must all be
+// found in the values of a log, "*" matches every log.
+func (s *logstore) query(c *call) (logs []map[string]string, e *apiError) {
+	from, err := strconv.ParseInt(c.query.Get("from"), 10, 64)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "ParameterInvalid", "invalid from: %v", c.query.Get("from"))
+	}
+	to, err := strconv.ParseInt(c.query.Get("to"), 10, 64)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "ParameterInvalid", "invalid to: %v", c.query.Get("to"))
+	}
+	topic := c.query.Get("topic")
+	terms := strings.Fields(c.query.Get("query"))
+	if len(terms) == 1 && terms[0] == "*" {
+		terms = nil
+	}
+
+	for _, sh := range s.shards {
+		for _, lg := range sh.groups {
+			if topic != "" && lg.GetTopic() != topic {
+				continue
+			}
+			for _, l := range lg.Logs {
+				t := int64(l.GetTime())
+				if t < from || t >= to {
+					continue
+				}
+				row := map[string]string{
+					"__time__":   strconv.FormatInt(t, 10),
+					"__topic__":  lg.GetTopic(),
+					"__source__": lg.GetSource(),
+				}
+				var values []string
+				for _, content := range l.Contents {
+					row[content.GetKey()] = content.GetValue()
+					values = append(values, content.GetValue())
+				}
+				if match(values, terms) {
+					logs = append(logs, row)
+				}
+			}
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		ti, _ := strconv.ParseInt(logs[i]["__time__"], 10, 64)
+		tj, _ := strconv.ParseInt(logs[j]["__time__"], 10, 64)
+		return ti < tj
+	})
+	return logs, nil
+}
+
+func match(values, terms []string) bool {
+	for _, term := range terms {
+		found := false
+		for _, v := range values {
+			if strings.Contains(v, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *logstore) getLogs(c *call) *apiError {
+	logs, e := s.query(c)
+	if e != nil {
+		return e
+	}
+
+	if c.query.Get("reverse") == "true" {
+		for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+			logs[i], logs[j] = logs[j], logs[i]
+		}
+	}
+	offset, err := strconv.Atoi(c.query.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	line, err := strconv.Atoi(c.query.Get("line"))
+	if err != nil || line <= 0 {
+		line = 100
+	}
+	if offset > len(logs) {
+		offset = len(logs)
+	}
+	if offset+line < len(logs) {
+		logs = logs[:offset+line]
+	}
+	logs = logs[offset:]
+	if logs == nil {
+		logs = []map[string]string{}
+	}
+
+	c.w.Header().Set(sls.ProgressHeader, "Complete")
+	c.w.Header().Set(sls.GetLogsCountHeader, strconv.Itoa(len(logs)))
+	return writeJSON(c.w, logs)
+}
+
+func (s *logstore) getHistograms(c *call) *apiError {
+	logs, e := s.query(c)
+	if e != nil {
+		return e
+	}
+
+	from, _ := strconv.ParseInt(c.query.Get("from"), 10, 64)
+	to, _ := strconv.ParseInt(c.query.Get("to"), 10, 64)
+	c.w.Header().Set(sls.ProgressHeader, "Complete")
+	c.w.Header().Set(sls.GetLogsCountHeader, strconv.Itoa(len(logs)))
+	return writeJSON(c.w, []sls.SingleHistogram{{
+		Progress: "Complete",
+		Count:    int64(len(logs)),
+		From:     from,
+		To:       to,
+	}})
+}
@@ -0,0 +1,459 @@
+// Package slstest provides an in-process SLS server for tests.
+//
+// The server speaks the subset of the SLS REST protocol used by the SDK:
+// projects, logstores, shards, cursors, PutLogs and PullLogs, GetLogs and
+// GetHistograms, indexes, consumer groups, logtail configs and machine
+// groups. Every request must be signed with the server's access key like
+// the real service does.
+//
+//	srv := slstest.NewServer("id", "secret")
+//	defer srv.Close()
+//	client := srv.NewClient()
+//	project, err := client.CreateProject("test-project", "")
+package slstest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sls "github.com/galaxydi/go-loghub"
+)
+
+// Server is an in-process SLS server.
+type Server struct {
+	URL      string // Base URL of the server, https://host:port
+	Endpoint string // Endpoint to give to the SDK, host:port
+
+	AccessKeyID     string
+	AccessKeySecret string
+
+	ts *httptest.Server
+
+	mu        sync.Mutex
+	projects  map[string]*project
+	requestID uint64
+}
+
+// NewServer starts a server accepting the requests signed with the given
+// access key. The caller should call Close when finished.
+func NewServer(accessKeyID, accessKeySecret string) *Server {
+	s := &Server{
+		AccessKeyID:     accessKeyID,
+		AccessKeySecret: accessKeySecret,
+		projects:        make(map[string]*project),
+	}
+	s.ts = httptest.NewTLSServer(s)
+	s.URL = s.ts.URL
+	s.Endpoint = s.ts.Listener.Addr().String()
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.ts.Close()
+}
+
+// HTTPClient returns an HTTP client that sends the requests of any project
+// to the server and trusts its certificate.
+func (s *Server) HTTPClient() *http.Client {
+	addr := s.ts.Listener.Addr().String()
+	transport := s.ts.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	// The certificate of httptest servers is issued for example.com.
+	transport.TLSClientConfig.ServerName = "example.com"
+	return &http.Client{Transport: transport}
+}
+
+// NewClient returns a client of the server.
+func (s *Server) NewClient() *sls.Client {
+	return &sls.Client{
+		Endpoint:        s.Endpoint,
+		AccessKeyID:     s.AccessKeyID,
+		AccessKeySecret: s.AccessKeySecret,
+		HTTPClient:      s.HTTPClient(),
+	}
+}
+
+// NewProject returns a handle of the project name on the server.
+// The project itself is not created.
+func (s *Server) NewProject(name string) *sls.LogProject {
+	p, _ := sls.NewLogProject(name, s.Endpoint, s.AccessKeyID, s.AccessKeySecret)
+	p.WithHTTPClient(s.HTTPClient())
+	return p
+}
+
+// apiError is an error returned to the SDK.
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func errorf(status int, code, format string, args ...interface{}) *apiError {
+	return &apiError{status: status, code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// call is a request being served.
+type call struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	project string
+	path    []string // Path segments
+	query   url.Values
+	body    []byte
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requestID++
+	w.Header().Set("x-log-requestid", fmt.Sprintf("%024X", s.requestID))
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "InvalidBody", "%v", err))
+		return
+	}
+
+	if e := s.authorize(r, body); e != nil {
+		writeError(w, e)
+		return
+	}
+
+	c := &call{
+		w:       w,
+		r:       r,
+		project: s.projectName(r),
+		query:   r.URL.Query(),
+		body:    body,
+	}
+	for _, seg := range strings.Split(strings.Trim(r.URL.Path, "/"), "/") {
+		if seg != "" {
+			c.path = append(c.path, seg)
+		}
+	}
+
+	if e := s.route(c); e != nil {
+		writeError(w, e)
+	}
+}
+
+// projectName returns the project of a request, addressed by the
+// x-log-project header or the first label of its virtual host.
+func (s *Server) projectName(r *http.Request) string {
+	if name := r.Header.Get("x-log-project"); name != "" {
+		return name
+	}
+	host := r.Host
+	if i := strings.Index(host, "."); i > 0 {
+		return host[:i]
+	}
+	return ""
+}
+
+func (s *Server) route(c *call) *apiError {
+	if c.project == "" {
+		return errorf(http.StatusBadRequest, "ProjectNotExist", "no project in request")
+	}
+
+	if len(c.path) == 0 {
+		return s.serveProject(c)
+	}
+
+	p, ok := s.projects[c.project]
+	if !ok {
+		return errorf(http.StatusNotFound, "ProjectNotExist", "The Project does not exist : %v", c.project)
+	}
+
+	switch c.path[0] {
+	case "logstores":
+		return p.serveLogStores(c)
+	case "configs":
+		return p.serveConfigs(c)
+	case "machinegroups":
+		return p.serveMachineGroups(c)
+	}
+	return errorf(http.StatusNotFound, "InvalidURI", "unknown resource: %v", c.r.URL.Path)
+}
+
+func (s *Server) serveProject(c *call) *apiError {
+	switch c.r.Method {
+	case "POST":
+		if _, ok := s.projects[c.project]; ok {
+			return errorf(http.StatusBadRequest, "ProjectAlreadyExist", "Project %v already exist", c.project)
+		}
+		var body struct {
+			ProjectName string `json:"projectName"`
+			Description string `json:"description"`
+		}
+		if err := json.Unmarshal(c.body, &body); err != nil {
+			return errorf(http.StatusBadRequest, "PostBodyInvalid", "%v", err)
+		}
+		s.projects[c.project] = newProject(c.project, body.Description)
+		return nil
+	case "GET":
+		p, ok := s.projects[c.project]
+		if !ok {
+			return errorf(http.StatusNotFound, "ProjectNotExist", "The Project does not exist : %v", c.project)
+		}
+		return writeJSON(c.w, map[string]interface{}{
+			"projectName":    p.name,
+			"description":    p.description,
+			"status":         "Normal",
+			"createTime":     p.createTime,
+			"lastModifyTime": p.createTime,
+		})
+	case "DELETE":
+		if _, ok := s.projects[c.project]; !ok {
+			return errorf(http.StatusNotFound, "ProjectNotExist", "The Project does not exist : %v", c.project)
+		}
+		delete(s.projects, c.project)
+		return nil
+	}
+	return errorf(http.StatusMethodNotAllowed, "MethodNotAllowed", "%v /", c.r.Method)
+}
+
+// authorize checks the 'Authorization' header of a request.
+func (s *Server) authorize(r *http.Request, body []byte) *apiError {
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "SLS4-HMAC-SHA256 ") {
+		return s.authorizeV4(r, body)
+	}
+	if !strings.HasPrefix(auth, "SLS ") {
+		return errorf(http.StatusUnauthorized, "Unauthorized", "missing or malformed Authorization header")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(auth, "SLS "), ":", 2)
+	if len(parts) != 2 || parts[0] != s.AccessKeyID {
+		return errorf(http.StatusUnauthorized, "Unauthorized", "unknown AccessKeyId")
+	}
+
+	if len(body) > 0 {
+		sum := fmt.Sprintf("%X", md5.Sum(body))
+		if r.Header.Get("Content-MD5") != sum {
+			return errorf(http.StatusBadRequest, "InvalidContentMD5", "Content-MD5 doesn't match body")
+		}
+	}
+
+	if parts[1] != signature(s.AccessKeySecret, r) {
+		return errorf(http.StatusUnauthorized, "SignatureNotMatch", "signature doesn't match")
+	}
+	return nil
+}
+
+// signature calculates the signature digest of a request the way the
+// SDK does it, with the hmac-sha1 signature method.
+func signature(secret string, r *http.Request) string {
+	var keys []string
+	headers := make(map[string]string)
+	for k, v := range r.Header {
+		l := strings.ToLower(k)
+		if strings.HasPrefix(l, "x-log-") || strings.HasPrefix(l, "x-acs-") {
+			headers[l] = strings.TrimSpace(v[0])
+			keys = append(keys, l)
+		}
+	}
+	sort.Strings(keys)
+	canoHeaders := make([]string, len(keys))
+	for i, k := range keys {
+		canoHeaders[i] = k + ":" + headers[k]
+	}
+
+	canoResource := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		vals := r.URL.Query()
+		var params []string
+		for k := range vals {
+			params = append(params, k)
+		}
+		sort.Strings(params)
+		for i, k := range params {
+			var param string
+			for _, v := range vals[k] {
+				param += k + "=" + v
+			}
+			params[i] = param
+		}
+		canoResource += "?" + strings.Join(params, "&")
+	}
+
+	signStr := r.Method + "\n" +
+		r.Header.Get("Content-MD5") + "\n" +
+		r.Header.Get("Content-Type") + "\n" +
+		r.Header.Get("Date") + "\n" +
+		strings.Join(canoHeaders, "\n") + "\n" +
+		canoResource
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(signStr))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// authorizeV4 checks the 'Authorization' header of a request signed with
+// signature V4. Any region is accepted.
+func (s *Server) authorizeV4(r *http.Request, body []byte) *apiError {
+	// SLS4-HMAC-SHA256 Credential=<AccessKeyId>/<Date>/<Region>/sls/aliyun_v4_request,Signature=<Signature>
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "SLS4-HMAC-SHA256 ")
+	parts := strings.SplitN(auth, ",", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "Credential=") || !strings.HasPrefix(parts[1], "Signature=") {
+		return errorf(http.StatusUnauthorized, "Unauthorized", "malformed Authorization header")
+	}
+	scope := strings.SplitN(strings.TrimPrefix(parts[0], "Credential="), "/", 2)
+	if len(scope) != 2 || scope[0] != s.AccessKeyID {
+		return errorf(http.StatusUnauthorized, "Unauthorized", "unknown AccessKeyId")
+	}
+
+	sum := sha256.Sum256(body)
+	if r.Header.Get("x-log-content-sha256") != hex.EncodeToString(sum[:]) {
+		return errorf(http.StatusBadRequest, "InvalidContentSHA256", "x-log-content-sha256 doesn't match body")
+	}
+	if strings.TrimPrefix(parts[1], "Signature=") != signatureV4(s.AccessKeySecret, scope[1], r) {
+		return errorf(http.StatusUnauthorized, "SignatureNotMatch", "signature doesn't match")
+	}
+	return nil
+}
+
+// signatureV4 calculates the signature of a request the way the SDK does
+// it with signature V4, where scope is <Date>/<Region>/sls/aliyun_v4_request.
+// It follows the same reading of the specification as the SDK, so it
+// doesn't tell whether SLS accepts the signatures.
+func signatureV4(secret, scope string, r *http.Request) string {
+	headers := map[string]string{"host": r.Host}
+	keys := []string{"host"}
+	for k, v := range r.Header {
+		l := strings.ToLower(k)
+		if l == "content-type" || l == "content-md5" ||
+			strings.HasPrefix(l, "x-log-") || strings.HasPrefix(l, "x-acs-") {
+			headers[l] = strings.TrimSpace(v[0])
+			keys = append(keys, l)
+		}
+	}
+	sort.Strings(keys)
+	var canoHeaders string
+	for _, k := range keys {
+		canoHeaders += k + ":" + headers[k] + "\n"
+	}
+
+	var params []string
+	for k, vals := range r.URL.Query() {
+		for _, v := range vals {
+			params = append(params, escapeV4(k)+"="+escapeV4(v))
+		}
+	}
+	sort.Strings(params)
+
+	canoRequest := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		strings.Join(params, "&") + "\n" +
+		canoHeaders + "\n" +
+		strings.Join(keys, ";") + "\n" +
+		r.Header.Get("x-log-content-sha256")
+	hash := sha256.Sum256([]byte(canoRequest))
+	signStr := "SLS4-HMAC-SHA256\n" +
+		r.Header.Get("x-log-date") + "\n" +
+		scope + "\n" +
+		hex.EncodeToString(hash[:])
+
+	fields := strings.Split(scope, "/")
+	key := []byte("aliyun_v4" + secret)
+	for _, f := range fields {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(f))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signStr))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// escapeV4 percent-encodes s like RFC 3986.
+func escapeV4(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) *apiError {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return errorf(http.StatusInternalServerError, "InternalServerError", "%v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-log-bodyrawsize", fmt.Sprintf("%v", len(buf)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+	return nil
+}
+
+func writeError(w http.ResponseWriter, e *apiError) {
+	buf, _ := json.Marshal(map[string]string{
+		"errorCode":    e.code,
+		"errorMessage": e.message,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	w.Write(buf)
+}
+
+// decodeJSON decodes the JSON body of a request.
+func (c *call) decodeJSON(v interface{}) *apiError {
+	d := json.NewDecoder(bytes.NewReader(c.body))
+	if err := d.Decode(v); err != nil {
+		return errorf(http.StatusBadRequest, "PostBodyInvalid", "%v", err)
+	}
+	return nil
+}
+
+// page returns the offset and size query parameters of a list request,
+// a negative offset is 0.
+func (c *call) page(defaultSize int) (offset, size int) {
+	if _, err := fmt.Sscan(c.query.Get("offset"), &offset); err != nil || offset < 0 {
+		offset = 0
+	}
+	if _, err := fmt.Sscan(c.query.Get("size"), &size); err != nil || size <= 0 {
+		size = defaultSize
+	}
+	return offset, size
+}
+
+// pageOf returns the names in page [offset, offset+size) of sorted names.
+func pageOf(names []string, offset, size int) []string {
+	sort.Strings(names)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(names) {
+		offset = len(names)
+	}
+	if size < 0 {
+		size = 0
+	}
+	end := offset + size
+	if end > len(names) {
+		end = len(names)
+	}
+	return append([]string{}, names[offset:end]...)
+}
+
+func now() uint32 {
+	return uint32(time.Now().Unix())
+}
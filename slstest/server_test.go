@@ -0,0 +1,313 @@
+package slstest_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	sls "github.com/galaxydi/go-loghub"
+	"github.com/galaxydi/go-loghub/slstest"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+type ServerTestSuite struct {
+	suite.Suite
+	server  *slstest.Server
+	client  *sls.Client
+	project *sls.LogProject
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.server = slstest.NewServer("mockAccessKeyID", "mockAccessKeySecret")
+	s.client = s.server.NewClient()
+	project, err := s.client.CreateProject("test-project", "slstest")
+	s.Nil(err)
+	s.project = project
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func newLogGroup(topic string, values ...string) *sls.LogGroup {
+	lg := &sls.LogGroup{
+		Topic:  proto.String(topic),
+		Source: proto.String("10.230.201.117"),
+	}
+	for _, v := range values {
+		lg.Logs = append(lg.Logs, &sls.Log{
+			Time: proto.Uint32(uint32(time.Now().Unix())),
+			Contents: []*sls.LogContent{{
+				Key:   proto.String("message"),
+				Value: proto.String(v),
+			}},
+		})
+	}
+	return lg
+}
+
+func (s *ServerTestSuite) TestProject() {
+	exist, err := s.client.CheckProjectExist("test-project")
+	s.Nil(err)
+	s.True(exist)
+
+	exist, err = s.client.CheckProjectExist("not-exist-project")
+	s.Nil(err)
+	s.False(exist)
+
+	_, err = s.client.CreateProject("test-project", "")
+	s.NotNil(err)
+
+	s.Nil(s.client.DeleteProject("test-project"))
+	_, err = s.client.GetProject("test-project")
+	s.NotNil(err)
+}
+
+func (s *ServerTestSuite) TestBadSignature() {
+	client := s.server.NewClient()
+	client.AccessKeySecret = "badAccessKeySecret"
+	_, err := client.GetProject("test-project")
+	s.NotNil(err)
+	slsErr, ok := err.(*sls.Error)
+	s.True(ok)
+	s.Equal("SignatureNotMatch", slsErr.Code)
+}
+
+func (s *ServerTestSuite) TestSignatureV4() {
+	p := s.server.NewProject("test-project")
+	p.WithSignatureVersion(sls.SignatureV4)
+	p.WithRegion("cn-hangzhou")
+	s.Nil(p.CreateLogStore("test-logstore", 1, 1))
+	store, err := p.GetLogStore("test-logstore")
+	s.Nil(err)
+	s.Nil(store.PutLogs(newLogGroup("topic", "v4")))
+	cursor, err := store.GetCursor(0, sls.OffsetOldest)
+	s.Nil(err)
+	gl, _, err := store.PullLogs(0, cursor, "", 10)
+	s.Nil(err)
+	s.Len(gl.LogGroups, 1)
+
+	p.AccessKeySecret = "badAccessKeySecret"
+	_, err = store.GetCursor(0, sls.OffsetOldest)
+	s.NotNil(err)
+	slsErr, ok := err.(*sls.Error)
+	s.True(ok)
+	s.Equal("SignatureNotMatch", slsErr.Code)
+}
+
+func (s *ServerTestSuite) TestVirtualHost() {
+	// The endpoint of the server is an IP, so the SDK addresses the project
+	// with a header; any hostname is dialed to the server too.
+	p := s.server.NewProject("test-project")
+	_, port, _ := net.SplitHostPort(s.server.Endpoint)
+	p.Endpoint = "log.example.com:" + port
+	s.Nil(p.CreateLogStore("test-logstore", 1, 1))
+	exist, err := s.project.CheckLogstoreExist("test-logstore")
+	s.Nil(err)
+	s.True(exist)
+}
+
+func (s *ServerTestSuite) TestLogStore() {
+	s.Nil(s.project.CreateLogStore("test-logstore", 7, 2))
+	s.NotNil(s.project.CreateLogStore("test-logstore", 7, 2))
+
+	exist, err := s.project.CheckLogstoreExist("test-logstore")
+	s.Nil(err)
+	s.True(exist)
+	exist, err = s.project.CheckLogstoreExist("not-exist-logstore")
+	s.Nil(err)
+	s.False(exist)
+
+	s.Nil(s.project.UpdateLogStore("test-logstore", 14, 2))
+	store, err := s.project.GetLogStore("test-logstore")
+	s.Nil(err)
+	s.Equal(14, store.TTL)
+	s.Equal(2, store.ShardCount)
+
+	names, err := s.project.ListLogStore()
+	s.Nil(err)
+	s.Equal([]string{"test-logstore"}, names)
+
+	shards, err := store.ListShards()
+	s.Nil(err)
+	s.Equal([]int{0, 1}, shards)
+
+	s.Nil(s.project.DeleteLogStore("test-logstore"))
+	_, err = s.project.GetLogStore("test-logstore")
+	s.NotNil(err)
+}
+
+func (s *ServerTestSuite) TestPutAndPullLogs() {
+	s.Nil(s.project.CreateLogStore("test-logstore", 7, 1))
+	store, err := s.project.GetLogStore("test-logstore")
+	s.Nil(err)
+
+	begin, err := store.GetCursor(0, sls.OffsetOldest)
+	s.Nil(err)
+
+	s.Nil(store.PutLogs(newLogGroup("demo", "first", "second")))
+	s.Nil(store.PutLogs(newLogGroup("demo", "third")))
+
+	end, err := store.GetCursor(0, sls.OffsetNewest)
+	s.Nil(err)
+	s.NotEqual(begin, end)
+
+	gl, next, err := store.PullLogs(0, begin, "", 1)
+	s.Nil(err)
+	s.Len(gl.LogGroups, 1)
+	s.Len(gl.LogGroups[0].Logs, 2)
+	s.Equal("demo", gl.LogGroups[0].GetTopic())
+	s.Equal("first", gl.LogGroups[0].Logs[0].Contents[0].GetValue())
+
+	gl, next, err = store.PullLogs(0, next, end, 10)
+	s.Nil(err)
+	s.Len(gl.LogGroups, 1)
+	s.Equal("third", gl.LogGroups[0].Logs[0].Contents[0].GetValue())
+	s.Equal(end, next)
+
+	gl, _, err = store.PullLogs(0, next, "", 10)
+	s.Nil(err)
+	s.Len(gl.LogGroups, 0)
+}
+
+func (s *ServerTestSuite) TestCompressTypes() {
+	s.Nil(s.project.CreateLogStore("test-logstore", 1, 1))
+	store, err := s.project.GetLogStore("test-logstore")
+	s.Nil(err)
+
+	types := []string{sls.CompressNone, sls.CompressLZ4, sls.CompressDeflate, sls.CompressZstd}
+	for _, ct := range types {
+		_, err = store.WithCompressType(ct)
+		s.Nil(err)
+		s.Nil(store.PutLogs(newLogGroup(ct, "compressed with "+ct)))
+	}
+	for i, ct := range types {
+		_, err = store.WithCompressType(ct)
+		s.Nil(err)
+		cursor, err := store.GetCursor(0, sls.OffsetOldest)
+		s.Nil(err)
+		gl, _, err := store.PullLogs(0, cursor, "", 10)
+		s.Nil(err)
+		s.Len(gl.LogGroups, len(types))
+		s.Equal(types[i], gl.LogGroups[i].GetTopic())
+	}
+}
+
+func (s *ServerTestSuite) TestGetLogs() {
+	s.Nil(s.project.CreateLogStore("test-logstore", 7, 2))
+	store, err := s.project.GetLogStore("test-logstore")
+	s.Nil(err)
+	s.Nil(store.PutLogs(newLogGroup("demo", "InternalServerError", "OK")))
+	s.Nil(store.PutLogs(newLogGroup("demo", "InternalServerError")))
+
+	from := time.Now().Add(-time.Minute).Unix()
+	to := time.Now().Add(time.Minute).Unix()
+	hist, err := store.GetHistograms("", from, to, "InternalServerError")
+	s.Nil(err)
+	s.Equal(int64(2), hist.Count)
+
+	resp, err := store.GetLogs("", from, to, "InternalServerError", 100, 0, false)
+	s.Nil(err)
+	s.Equal(int64(2), resp.Count)
+	s.Equal("demo", resp.Logs[0]["__topic__"])
+	s.Equal("InternalServerError", resp.Logs[0]["message"])
+
+	resp, err = store.GetLogs("", from, to, "*", 1, 0, false)
+	s.Nil(err)
+	s.Equal(int64(1), resp.Count)
+}
+
+func (s *ServerTestSuite) TestIndex() {
+	s.Nil(s.project.CreateLogStore("test-logstore", 7, 1))
+	store, err := s.project.GetLogStore("test-logstore")
+	s.Nil(err)
+
+	index := sls.Index{
+		TTL: 7,
+		Line: &sls.IndexLine{
+			Token:         []string{",", ":", " "},
+			CaseSensitive: false,
+		},
+	}
+	s.Nil(store.CreateIndex(index))
+	index.TTL = 14
+	s.Nil(store.UpdateIndex(index))
+	got, err := store.GetIndex()
+	s.Nil(err)
+	s.Equal(14, got.TTL)
+	s.Nil(store.DeleteIndex())
+}
+
+func (s *ServerTestSuite) TestConfigAndMachineGroup() {
+	config := &sls.LogConfig{
+		Name:      "test-config",
+		InputType: "file",
+		InputDetail: sls.InputDetail{
+			LogType:     "common_reg_log",
+			LogPath:     "/var/log",
+			FilePattern: "*.log",
+		},
+		OutputType: "LogService",
+		OutputDetail: sls.OutputDetail{
+			ProjectName:  "test-project",
+			LogStoreName: "test-logstore",
+		},
+	}
+	s.Nil(s.project.CreateConfig(config))
+	exist, err := s.project.CheckConfigExist("test-config")
+	s.Nil(err)
+	s.True(exist)
+	config.InputDetail.FilePattern = "*.txt"
+	s.Nil(s.project.UpdateConfig(config))
+	got, err := s.project.GetConfig("test-config")
+	s.Nil(err)
+	s.Equal("*.txt", got.InputDetail.FilePattern)
+	names, total, err := s.project.ListConfig(0, 10)
+	s.Nil(err)
+	s.Equal(1, total)
+	s.Equal([]string{"test-config"}, names)
+	names, _, err = s.project.ListConfig(-1, 10) // From the first one
+	s.Nil(err)
+	s.Equal([]string{"test-config"}, names)
+
+	group := &sls.MachineGroup{
+		Name:          "test-group",
+		MachineIDType: "ip",
+		MachineIDList: []string{"127.0.0.1"},
+	}
+	s.Nil(s.project.CreateMachineGroup(group))
+	exist, err = s.project.CheckMachineGroupExist("test-group")
+	s.Nil(err)
+	s.True(exist)
+	group.MachineIDList = []string{"127.0.0.2"}
+	s.Nil(s.project.UpdateMachineGroup(group))
+	gotGroup, err := s.project.GetMachineGroup("test-group")
+	s.Nil(err)
+	s.Equal([]string{"127.0.0.2"}, gotGroup.MachineIDList)
+	machines, _, err := gotGroup.ListMachines()
+	s.Nil(err)
+	s.Len(machines, 0)
+
+	s.Nil(s.project.ApplyConfigToMachineGroup("test-config", "test-group"))
+	configs, err := gotGroup.GetAppliedConfigs()
+	s.Nil(err)
+	s.Equal([]string{"test-config"}, configs)
+	groups, err := s.project.GetAppliedMachineGroups("test-config")
+	s.Nil(err)
+	s.Equal([]string{"test-group"}, groups)
+	s.Nil(s.project.RemoveConfigFromMachineGroup("test-config", "test-group"))
+	configs, err = gotGroup.GetAppliedConfigs()
+	s.Nil(err)
+	s.Len(configs, 0)
+
+	s.Nil(s.project.DeleteMachineGroup("test-group"))
+	s.Nil(s.project.DeleteConfig("test-config"))
+	exist, err = s.project.CheckConfigExist("test-config")
+	s.Nil(err)
+	s.False(exist)
+}
@@ -0,0 +1,113 @@
+package sls
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Tracer starts a span around each request of the SDK methods, e.g. to
+// adapt OpenTelemetry. Implementations must be safe for concurrent use.
+type Tracer interface {
+	// StartSpan starts the span of operation op, e.g. "PullLogs", as a child
+	// of the span in ctx if any, and returns a context holding the new span.
+	StartSpan(ctx context.Context, op string) (context.Context, Span)
+}
+
+// Span is the span of a request started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span, see the Attr constants.
+	SetAttribute(key string, value interface{})
+	// TraceParent returns the W3C traceparent of the span, which is sent in
+	// the 'traceparent' header of the request, or "" to send none.
+	TraceParent() string
+	// End ends the span, err is the failure of the request, if any.
+	End(err error)
+}
+
+// Attributes of the spans set by the SDK.
+const (
+	AttrProject       = "sls.project"
+	AttrLogStore      = "sls.logstore"
+	AttrShard         = "sls.shard"
+	AttrOperation     = "sls.operation"
+	AttrRequestID     = "sls.request_id" // Value of the 'x-log-requestid' header
+	AttrErrorCode     = "sls.error_code"
+	AttrAttempts      = "sls.attempts"
+	AttrRequestBytes  = "sls.request_bytes"
+	AttrResponseBytes = "sls.response_bytes"
+	AttrStatusCode    = "http.status_code"
+)
+
+// FormatTraceParent formats a W3C traceparent header of version 00, see
+// https://www.w3.org/TR/trace-context/#traceparent-header.
+func FormatTraceParent(traceID [16]byte, spanID [8]byte, sampled bool) string {
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(traceID[:]) + "-" + hex.EncodeToString(spanID[:]) + "-" + flags
+}
+
+// startSpan starts the span of a request with project's Tracer, it returns
+// a nil span if the project has no Tracer.
+func startSpan(ctx context.Context, project *LogProject, op, uri string, headers map[string]string,
+	body []byte) (context.Context, Span) {
+	if project.Tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := project.Tracer.StartSpan(ctx, op)
+	span.SetAttribute(AttrProject, project.Name)
+	span.SetAttribute(AttrOperation, op)
+	if store := logStoreOf(uri); store != "" {
+		span.SetAttribute(AttrLogStore, store)
+	}
+	if shard, ok := shardOf(uri); ok {
+		span.SetAttribute(AttrShard, shard)
+	}
+	span.SetAttribute(AttrRequestBytes, len(body))
+	if tp := span.TraceParent(); tp != "" {
+		headers["traceparent"] = tp
+	}
+	return ctx, span
+}
+
+// endSpan sets the result of a request on its span and ends it.
+func endSpan(span Span, resp *http.Response, attempts int, err error) {
+	span.SetAttribute(AttrAttempts, attempts)
+	if resp != nil {
+		span.SetAttribute(AttrStatusCode, resp.StatusCode)
+		if id := resp.Header.Get("x-log-requestid"); id != "" {
+			span.SetAttribute(AttrRequestID, id)
+		}
+		if resp.ContentLength >= 0 {
+			span.SetAttribute(AttrResponseBytes, resp.ContentLength)
+		}
+	}
+	if e := slsError(err); e != nil {
+		span.SetAttribute(AttrErrorCode, e.Code)
+		if e.HTTPCode != 0 {
+			span.SetAttribute(AttrStatusCode, e.HTTPCode)
+		}
+		if e.RequestID != "" {
+			span.SetAttribute(AttrRequestID, e.RequestID)
+		}
+	}
+	span.End(err)
+}
+
+// shardOf returns the shard of a request uri like
+// "/logstores/<name>/shards/<shard>".
+func shardOf(uri string) (int, bool) {
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	parts := strings.Split(uri, "/")
+	if len(parts) < 5 || parts[1] != "logstores" || parts[3] != "shards" {
+		return 0, false
+	}
+	shard, err := strconv.Atoi(parts[4])
+	return shard, err == nil
+}
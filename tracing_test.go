@@ -0,0 +1,138 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+type spanKey struct{}
+
+// recordTracer records the spans it starts.
+type recordTracer struct {
+	mu    sync.Mutex
+	spans []*recordSpan
+}
+
+type recordSpan struct {
+	op     string
+	parent *recordSpan
+	attrs  map[string]interface{}
+	ended  bool
+	err    error
+}
+
+func (t *recordTracer) StartSpan(ctx context.Context, op string) (context.Context, Span) {
+	span := &recordSpan{op: op, attrs: make(map[string]interface{})}
+	span.parent, _ = ctx.Value(spanKey{}).(*recordSpan)
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *recordSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordSpan) TraceParent() string {
+	return FormatTraceParent([16]byte{0x4b, 0xf9, 0x2f, 0x35}, [8]byte{0x00, 0xf0, 0x67, 0xaa}, true)
+}
+
+func (s *recordSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+func TestTracingSpans(t *testing.T) {
+	rt := &scriptTransport{script: []*Error{
+		{Code: "ServerBusy", HTTPCode: http.StatusServiceUnavailable},
+	}}
+	p := newRetryTestProject(rt)
+	tracer := &recordTracer{}
+	p.WithTracer(tracer)
+
+	var spanInInterceptor *recordSpan
+	p.WithInterceptors(func(ctx context.Context, req *Request, next Handler) (*http.Response, error) {
+		spanInInterceptor, _ = ctx.Value(spanKey{}).(*recordSpan)
+		return next(ctx, req)
+	})
+
+	parent := &recordSpan{op: "handler"}
+	ctx := context.WithValue(context.Background(), spanKey{}, parent)
+	store := &LogStore{Name: "app_log", project: p}
+	if _, err := store.GetCursorWithContext(ctx, 3, "end"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("expected 1 span, got %v", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.op != "GetCursor" || !span.ended || span.err != nil || span.parent != parent {
+		t.Errorf("unexpected span: %+v", span)
+	}
+	if spanInInterceptor != span {
+		t.Errorf("the interceptors don't get the context of the span")
+	}
+	for k, v := range map[string]interface{}{
+		AttrProject:    "test-retry",
+		AttrLogStore:   "app_log",
+		AttrShard:      3,
+		AttrOperation:  "GetCursor",
+		AttrAttempts:   2,
+		AttrStatusCode: http.StatusOK,
+	} {
+		if span.attrs[k] != v {
+			t.Errorf("attribute %v is %v, expected %v", k, span.attrs[k], v)
+		}
+	}
+
+	const traceParent = "00-4bf92f35000000000000000000000000-00f067aa00000000-01"
+	for _, req := range rt.reqs {
+		if tp := req.Header.Get("traceparent"); tp != traceParent {
+			t.Errorf("expected traceparent %v, got %q", traceParent, tp)
+		}
+	}
+}
+
+func TestTracingFailedRequest(t *testing.T) {
+	rt := &scriptTransport{script: []*Error{
+		{Code: "LogStoreNotExist", HTTPCode: http.StatusNotFound, RequestID: "5C2E6C6D9F7A0C3B"},
+	}}
+	p := newRetryTestProject(rt)
+	tracer := &recordTracer{}
+	p.WithTracer(tracer)
+
+	_, err := p.GetLogStore("app_log")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	span := tracer.spans[0]
+	if span.err != err || span.attrs[AttrErrorCode] != "LogStoreNotExist" ||
+		span.attrs[AttrStatusCode] != http.StatusNotFound {
+		t.Errorf("unexpected span: %+v", span)
+	}
+	if _, ok := span.attrs[AttrShard]; ok {
+		t.Errorf("unexpected shard attribute: %+v", span.attrs)
+	}
+}
+
+func TestShardOf(t *testing.T) {
+	for uri, expected := range map[string]int{
+		"/logstores/app_log/shards/0?type=cursor&from=end": 0,
+		"/logstores/app_log/shards/12":                     12,
+		"/logstores/app_log/shards/lb":                     -1,
+		"/logstores/app_log/shards":                        -1,
+		"/logstores/app_log":                               -1,
+	} {
+		shard, ok := shardOf(uri)
+		if !ok {
+			shard = -1
+		}
+		if shard != expected {
+			t.Errorf("shardOf(%q) = %v, expected %v", uri, shard, expected)
+		}
+	}
+}